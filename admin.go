@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"expvar"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var (
+	adminAddrFlag  = flag.String("admin-addr", "", "(optional) -admin-addr Address of a separate server for health, readiness, metrics, stats and pprof, e.g. :9090")
+	statsResetFlag = flag.Bool("stats-reset", false, "(optional) -stats-reset Lets POST /stats/reset on the admin server zero the request counters")
+	expvarFlag     = flag.Bool("expvar", false, "(optional) -expvar Publishes the request counters under /debug/vars on the admin server")
+)
+
+// newAdminServer returns a server for the operational endpoints, kept off
+// the public port so it can be firewalled separately.
+func newAdminServer() *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", healthHandler)
+	mux.HandleFunc("/readyz", readyHandler)
+	mux.HandleFunc("/metrics", metricsHandler)
+	mux.HandleFunc("/stats", statsHandler)
+	if *statsResetFlag {
+		mux.HandleFunc("/stats/reset", statsResetHandler)
+	}
+	if *statusPathFlag != "" {
+		mux.HandleFunc(*statusPathFlag, statusPageHandler)
+	}
+	if *expvarFlag {
+		expvar.Publish("gohttpserver", expvar.Func(func() any { return currentStatsReport() }))
+		mux.Handle("/debug/vars", expvar.Handler())
+	}
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return &http.Server{Handler: mux}
+}
+
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	if *healthDeepFlag {
+		err := deepHealth.check()
+		if err != nil {
+			http.Error(w, "unhealthy: "+err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+func readyHandler(w http.ResponseWriter, r *http.Request) {
+	if serverState.Load() != stateReady {
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ready")
+}
+
+// metricsHandler writes the request counters in the Prometheus text format.
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	requests, written, statuses := stats.snapshot()
+	codes := make([]int, 0, len(statuses))
+	for code := range statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintln(w, "# TYPE gohttpserver_requests_total counter")
+	fmt.Fprintf(w, "gohttpserver_requests_total %d\n", requests)
+	fmt.Fprintln(w, "# TYPE gohttpserver_responses_total counter")
+	for _, code := range codes {
+		fmt.Fprintf(w, "gohttpserver_responses_total{code=\"%d\"} %d\n", code, statuses[code])
+	}
+	fmt.Fprintln(w, "# TYPE gohttpserver_written_bytes_total counter")
+	fmt.Fprintf(w, "gohttpserver_written_bytes_total %d\n", written)
+	fmt.Fprintln(w, "# TYPE gohttpserver_in_flight_requests gauge")
+	fmt.Fprintf(w, "gohttpserver_in_flight_requests %d\n", stats.inFlight.Load())
+	if *maxOpenFilesFlag > 0 {
+		fmt.Fprintln(w, "# TYPE gohttpserver_open_files gauge")
+		fmt.Fprintf(w, "gohttpserver_open_files %d\n", stats.openFiles.Load())
+	}
+}
+
+// statsReport is the JSON body of /stats. Statuses is keyed by the code as a
+// string since JSON object keys must be strings.
+type statsReport struct {
+	UptimeSeconds int64
+	Requests      int64
+	Written       int64
+	Statuses      map[string]int64
+	InFlight      int64
+	OpenFiles     int64
+}
+
+// currentStatsReport fills a statsReport from the live counters. It backs
+// both /stats and the gohttpserver expvar.
+func currentStatsReport() statsReport {
+	requests, written, statuses := stats.snapshot()
+	report := statsReport{
+		UptimeSeconds: int64(time.Since(startTime) / time.Second),
+		Requests:      requests,
+		Written:       written,
+		Statuses:      make(map[string]int64, len(statuses)),
+		InFlight:      stats.inFlight.Load(),
+		OpenFiles:     stats.openFiles.Load(),
+	}
+	for code, count := range statuses {
+		report.Statuses[strconv.Itoa(code)] = count
+	}
+	return report
+}
+
+// statsHandler writes the same counters as /metrics as one JSON object, for
+// dashboards that do not speak Prometheus.
+func statsHandler(w http.ResponseWriter, r *http.Request) {
+	report := currentStatsReport()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+func statsResetHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	stats.reset()
+	fmt.Fprintln(w, "reset")
+}
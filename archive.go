@@ -0,0 +1,244 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"flag"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	archiveFlag = flag.String("archive", "", "(optional) -archive Path to a .zip, .tar, .tar.gz or .tgz whose contents are served instead of -d")
+)
+
+// openArchive loads the archive at name into memory and returns it as a
+// file system for http.FileServer. Everything is read up front so files
+// can be seeked for range requests, which neither format allows directly.
+func openArchive(name string) (http.FileSystem, error) {
+	fsys := &archiveFS{entries: map[string]*archiveEntry{}}
+	fsys.addDir(".", time.Time{})
+	var err error
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		err = fsys.loadZip(name)
+	case strings.HasSuffix(name, ".tar"):
+		err = fsys.loadTar(name, false)
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		err = fsys.loadTar(name, true)
+	default:
+		return nil, errors.New("[ERROR] Unknown archive type, expected .zip, .tar, .tar.gz or .tgz: " + name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return http.FS(fsys), nil
+}
+
+// archiveFS is a read-only in-memory fs.FS keyed by slash separated paths
+// without a leading slash, with "." as the root.
+type archiveFS struct {
+	entries map[string]*archiveEntry
+}
+
+type archiveEntry struct {
+	name     string
+	data     []byte
+	mode     fs.FileMode
+	modTime  time.Time
+	children map[string]*archiveEntry
+}
+
+func (fsys *archiveFS) loadZip(name string) error {
+	r, err := zip.OpenReader(name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			fsys.addDir(f.Name, f.Modified)
+			continue
+		}
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		fsys.addFile(f.Name, data, f.Modified)
+	}
+	return nil
+}
+
+func (fsys *archiveFS) loadTar(name string, gzipped bool) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if gzipped {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			fsys.addDir(hdr.Name, hdr.ModTime)
+		case tar.TypeReg:
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return err
+			}
+			fsys.addFile(hdr.Name, data, hdr.ModTime)
+		}
+	}
+}
+
+// cleanArchivePath turns an archive member name into an fs.FS path, or ""
+// for names that would escape the root.
+func cleanArchivePath(name string) string {
+	name = path.Clean("/" + strings.ReplaceAll(name, "\\", "/"))
+	if name == "/" {
+		return "."
+	}
+	name = strings.TrimPrefix(name, "/")
+	if !fs.ValidPath(name) {
+		return ""
+	}
+	return name
+}
+
+// addDir adds the directory name and any missing parents. A directory
+// already created as a parent picks up modTime when it is listed itself.
+func (fsys *archiveFS) addDir(name string, modTime time.Time) *archiveEntry {
+	name = cleanArchivePath(name)
+	if name == "" {
+		return nil
+	}
+	if dir, ok := fsys.entries[name]; ok {
+		if dir.mode.IsDir() && !modTime.IsZero() {
+			dir.modTime = modTime
+		}
+		return dir
+	}
+	dir := &archiveEntry{name: path.Base(name), mode: fs.ModeDir | 0555, modTime: modTime, children: map[string]*archiveEntry{}}
+	if name != "." {
+		parent := fsys.addDir(path.Dir(name), time.Time{})
+		if parent == nil || !parent.mode.IsDir() {
+			return nil
+		}
+		parent.children[dir.name] = dir
+	}
+	fsys.entries[name] = dir
+	return dir
+}
+
+func (fsys *archiveFS) addFile(name string, data []byte, modTime time.Time) {
+	name = cleanArchivePath(name)
+	if name == "" || name == "." {
+		return
+	}
+	if existing, ok := fsys.entries[name]; ok && existing.mode.IsDir() {
+		return
+	}
+	parent := fsys.addDir(path.Dir(name), time.Time{})
+	if parent == nil || !parent.mode.IsDir() {
+		return
+	}
+	file := &archiveEntry{name: path.Base(name), data: data, mode: 0444, modTime: modTime}
+	fsys.entries[name] = file
+	parent.children[file.name] = file
+}
+
+func (fsys *archiveFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	entry, ok := fsys.entries[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return &archiveFile{entry: entry, Reader: bytes.NewReader(entry.data)}, nil
+}
+
+// archiveFile is an open archiveEntry. The embedded reader makes it
+// seekable, which http.FileServer needs for range requests.
+type archiveFile struct {
+	entry *archiveEntry
+	*bytes.Reader
+	listed []fs.DirEntry
+	offset int
+}
+
+func (f *archiveFile) Stat() (fs.FileInfo, error) { return archiveInfo{f.entry}, nil }
+
+func (f *archiveFile) Close() error { return nil }
+
+// ReadDir lists the children of a directory in name order, following the
+// fs.ReadDirFile contract for n.
+func (f *archiveFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !f.entry.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: f.entry.name, Err: errors.New("not a directory")}
+	}
+	if f.listed == nil {
+		f.listed = make([]fs.DirEntry, 0, len(f.entry.children))
+		for _, child := range f.entry.children {
+			f.listed = append(f.listed, fs.FileInfoToDirEntry(archiveInfo{child}))
+		}
+		sort.Slice(f.listed, func(i, j int) bool { return f.listed[i].Name() < f.listed[j].Name() })
+	}
+	remaining := f.listed[f.offset:]
+	if n <= 0 {
+		f.offset = len(f.listed)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(remaining) {
+		n = len(remaining)
+	}
+	f.offset += n
+	return remaining[:n], nil
+}
+
+// archiveInfo is the fs.FileInfo of an archiveEntry.
+type archiveInfo struct {
+	entry *archiveEntry
+}
+
+func (i archiveInfo) Name() string       { return i.entry.name }
+func (i archiveInfo) Size() int64        { return int64(len(i.entry.data)) }
+func (i archiveInfo) Mode() fs.FileMode  { return i.entry.mode }
+func (i archiveInfo) ModTime() time.Time { return i.entry.modTime }
+func (i archiveInfo) IsDir() bool        { return i.entry.mode.IsDir() }
+func (i archiveInfo) Sys() any           { return nil }
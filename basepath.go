@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"net/http"
+	"strings"
+)
+
+var (
+	basePathFlag = flag.String("base-path", "", "(optional) -base-path URL prefix, e.g. /files, the server is mounted under behind a reverse proxy. Requests outside it get 404")
+)
+
+// parseBasePath normalizes -base-path to a leading slash and no trailing
+// one, with "" meaning the site is at the root.
+func parseBasePath(value string) (string, error) {
+	value = strings.TrimSuffix(value, "/")
+	if value != "" && !strings.HasPrefix(value, "/") {
+		return "", errors.New("[ERROR] -base-path must start with /: " + value)
+	}
+	return value, nil
+}
+
+// basePathHandler strips -base-path before the request reaches the file
+// server and redirects the bare prefix to prefix/, so the relative links in
+// listings resolve under it.
+func basePathHandler(handler http.Handler) http.Handler {
+	strip := http.StripPrefix(*basePathFlag, handler)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == *basePathFlag {
+			target := *basePathFlag + "/"
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+			return
+		}
+		if !strings.HasPrefix(r.URL.Path, *basePathFlag+"/") {
+			http.NotFound(w, r)
+			return
+		}
+		strip.ServeHTTP(w, r)
+	})
+}
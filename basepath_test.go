@@ -0,0 +1,119 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func newBasePathTestSite(t *testing.T) *testSite {
+	t.Helper()
+	*basePathFlag = "/files"
+	t.Cleanup(func() { *basePathFlag = "" })
+	return newTestSite(t, map[string]string{
+		"top.txt":              "top",
+		"a/b/c.txt":            "nested",
+		"docs/index.html":      "<p>docs</p>",
+		"docs/deep/index.html": "<p>deep</p>",
+	})
+}
+
+func TestBasePathNested(t *testing.T) {
+	site := newBasePathTestSite(t)
+
+	tests := []struct {
+		target string
+		status int
+		body   string
+	}{
+		{"/files/top.txt", http.StatusOK, "top"},
+		{"/files/a/b/c.txt", http.StatusOK, "nested"},
+		{"/files/docs/", http.StatusOK, "<p>docs</p>"},
+		{"/files/docs/deep/", http.StatusOK, "<p>deep</p>"},
+		{"/a/b/c.txt", http.StatusNotFound, ""},
+		{"/filesa/b/c.txt", http.StatusNotFound, ""},
+		{"/top.txt", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		w := site.get(http.MethodGet, tt.target, nil)
+		if w.Code != tt.status {
+			t.Errorf("GET %s: status %d, want %d", tt.target, w.Code, tt.status)
+			continue
+		}
+		if tt.body != "" && w.Body.String() != tt.body {
+			t.Errorf("GET %s: body %q, want %q", tt.target, w.Body.String(), tt.body)
+		}
+	}
+}
+
+// TestBasePathRedirects checks that redirects, once resolved against the
+// request URL the way a client does, stay under the prefix.
+func TestBasePathRedirects(t *testing.T) {
+	site := newBasePathTestSite(t)
+
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"/files", "/files/"},
+		{"/files?x=1", "/files/?x=1"},
+		{"/files/a", "/files/a/"},
+		{"/files/a/b", "/files/a/b/"},
+		{"/files/docs/deep", "/files/docs/deep/"},
+		{"/files/docs/index.html", "/files/docs/"},
+		{"/files/docs/deep/index.html", "/files/docs/deep/"},
+	}
+	for _, tt := range tests {
+		w := site.get(http.MethodGet, tt.target, nil)
+		if w.Code != http.StatusMovedPermanently {
+			t.Errorf("GET %s: status %d, want 301", tt.target, w.Code)
+			continue
+		}
+		location, err := url.Parse(w.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		base, _ := url.Parse("http://example.com" + tt.target)
+		if got := base.ResolveReference(location).RequestURI(); got != tt.want {
+			t.Errorf("GET %s: redirected to %s, want %s", tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestBasePathRootRedirect(t *testing.T) {
+	tests := []struct {
+		rootRedirect string
+		want         string
+	}{
+		{"welcome.html", "/files/welcome.html"},
+		{"docs/", "/files/docs/"},
+		{"/files/top.txt", "/files/top.txt"},
+		{"https://example.org/", "https://example.org/"},
+	}
+	t.Cleanup(func() { *rootRedirectFlag = "" })
+	for _, tt := range tests {
+		*rootRedirectFlag = tt.rootRedirect
+		site := newBasePathTestSite(t)
+		w := site.get(http.MethodGet, "/files/", nil)
+		if got := w.Header().Get("Location"); w.Code != http.StatusFound || got != tt.want {
+			t.Errorf("-root-redirect %s: status %d to %q, want 302 to %q", tt.rootRedirect, w.Code, got, tt.want)
+		}
+	}
+}
+
+// TestBasePathRewriteLogged checks that the access log shows the rewritten
+// path although http.StripPrefix hands the rewrite a copy of the URL.
+func TestBasePathRewriteLogged(t *testing.T) {
+	rewriteRules = []rewriteRule{{from: "/old/", to: "/a/b/"}}
+	t.Cleanup(func() { rewriteRules = nil })
+	site := newBasePathTestSite(t)
+
+	w := site.get(http.MethodGet, "/files/old/c.txt", nil)
+	if w.Code != http.StatusOK || w.Body.String() != "nested" {
+		t.Fatalf("GET /files/old/c.txt: %d %q, want the rewritten file", w.Code, w.Body.String())
+	}
+	entry := site.lastEntry(t)
+	if entry.URL != "/files/a/b/c.txt" || entry.RequestURI != "/files/old/c.txt" {
+		t.Errorf("logged URL %q and RequestURI %q, want /files/a/b/c.txt and /files/old/c.txt", entry.URL, entry.RequestURI)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"regexp"
+)
+
+var (
+	blockUAFlags    stringList
+	dropFlag        = flag.Bool("drop", false, "(optional) -drop Closes the connection on requests matched by -block-ua instead of answering 403")
+	blockUAPatterns []*regexp.Regexp
+)
+
+func init() {
+	flag.Var(&blockUAFlags, "block-ua", "(optional) -block-ua Regex matched against the User-Agent, matching requests get 403 Forbidden. Repeatable")
+}
+
+func parseBlockUAPatterns(values []string) ([]*regexp.Regexp, error) {
+	patterns := make([]*regexp.Regexp, 0, len(values))
+	for _, value := range values {
+		pattern, err := regexp.Compile(value)
+		if err != nil {
+			return nil, errors.New("[ERROR] Invalid -block-ua pattern " + value + ": " + err.Error())
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// blockUAHandler refuses requests whose User-Agent matches a -block-ua
+// pattern, logging the pattern that matched so the list can be tuned.
+func blockUAHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent := r.UserAgent()
+		for _, pattern := range blockUAPatterns {
+			if !pattern.MatchString(userAgent) {
+				continue
+			}
+			log.Printf("[WARN] Blocked request from %s for %q, User-Agent %q matched %s", r.RemoteAddr, r.RequestURI, userAgent, pattern)
+			if *dropFlag {
+				if notes := notesFrom(r); notes != nil {
+					notes.dropped = true
+					return
+				}
+				panic(http.ErrAbortHandler)
+			}
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
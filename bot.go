@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"regexp"
+)
+
+var (
+	botPatternFlags stringList
+	botLogFlag      = flag.String("bot-log", "", "(optional) -bot-log File that requests from bots are logged to as JSON instead of the access log")
+	botPatterns     []*regexp.Regexp
+	botLog          *logFile
+)
+
+// defaultBotPatterns match the User-Agent of the common crawlers, used when
+// no -bot-pattern is given.
+var defaultBotPatterns = []string{
+	`(?i)googlebot|bingbot|slurp|duckduckbot|baiduspider|yandexbot|applebot|gptbot`,
+	`(?i)facebookexternalhit|twitterbot|linkedinbot|ahrefsbot|semrushbot|mj12bot|petalbot`,
+	`(?i)\bbot\b|crawler|spider`,
+}
+
+func init() {
+	flag.Var(&botPatternFlags, "bot-pattern", "(optional) -bot-pattern Regex matched against the User-Agent to mark a request as a bot. Repeatable, replaces the built in crawler list")
+}
+
+func parseBotPatterns(values []string) ([]*regexp.Regexp, error) {
+	if len(values) == 0 {
+		values = defaultBotPatterns
+	}
+	patterns := make([]*regexp.Regexp, 0, len(values))
+	for _, value := range values {
+		pattern, err := regexp.Compile(value)
+		if err != nil {
+			return nil, errors.New("[ERROR] Invalid bot pattern " + value + ": " + err.Error())
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+func isBot(userAgent string) bool {
+	for _, pattern := range botPatterns {
+		if pattern.MatchString(userAgent) {
+			return true
+		}
+	}
+	return false
+}
+
+// setupBotLog opens -bot-log and registers it for reopening on SIGHUP.
+func setupBotLog() error {
+	f, err := openLogFile(*botLogFlag, logFileMode, logDirMode)
+	if err != nil {
+		return err
+	}
+	hangupFiles = append(hangupFiles, f)
+	botLog = f
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	canonicalPathFlag = flag.Bool("canonical-path", false, "(optional) -canonical-path Redirects with 301 to the on-disk spelling of a path when the request differs from it in case or Unicode normalization")
+)
+
+// canonicalPathHandler redirects requests that only reach a file because
+// the file system ignores case or normalization to the name the file
+// actually has, so every resource has one URL. On a file system that
+// matches names exactly nothing is redirected, and a misspelled path gets
+// the file server's 404.
+func canonicalPathHandler(handler http.Handler, root http.FileSystem) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !reachedByFolding(root, r.URL.Path) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		canonical, ok := canonicalPath(root, r.URL.Path)
+		if !ok || canonical == r.URL.Path {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		target := url.URL{Path: *basePathFlag + canonical, RawQuery: r.URL.RawQuery}
+		http.Redirect(w, r, target.String(), http.StatusMovedPermanently)
+	})
+}
+
+// reachedByFolding reports whether urlPath opens, and the same path with its
+// case or normalization changed opens the same file. Only then can the
+// spelling on disk differ from the request, so only then are directories
+// listed to find it.
+func reachedByFolding(root http.FileSystem, urlPath string) bool {
+	name := path.Clean("/" + urlPath)
+	variant := strings.ToUpper(name)
+	if variant == name {
+		variant = strings.ToLower(name)
+	}
+	if variant == name {
+		variant = norm.NFD.String(name)
+	}
+	if variant == name {
+		variant = norm.NFC.String(name)
+	}
+	if variant == name {
+		return false
+	}
+	info, err := openInfo(root, name)
+	if err != nil {
+		return false
+	}
+	other, err := openInfo(root, variant)
+	return err == nil && os.SameFile(info, other)
+}
+
+func openInfo(root http.FileSystem, name string) (os.FileInfo, error) {
+	f, err := root.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Stat()
+}
+
+// canonicalPath spells urlPath segment by segment the way the entries are
+// named in root. It reports false when a segment has no unambiguous match,
+// leaving the request for the file server to answer.
+func canonicalPath(root http.FileSystem, urlPath string) (string, bool) {
+	trimmed := strings.Trim(urlPath, "/")
+	if trimmed == "" {
+		return urlPath, false
+	}
+	segments := strings.Split(trimmed, "/")
+	dir := "/"
+	for i, segment := range segments {
+		name, ok := canonicalName(root, dir, segment)
+		if !ok {
+			return "", false
+		}
+		segments[i] = name
+		dir = path.Join(dir, name)
+	}
+	canonical := "/" + strings.Join(segments, "/")
+	if strings.HasSuffix(urlPath, "/") {
+		canonical += "/"
+	}
+	return canonical, true
+}
+
+// canonicalName finds the entry of dir that segment refers to. An exact
+// name wins, then a single entry equal under NFC, then a single entry
+// equal under NFC ignoring case.
+func canonicalName(root http.FileSystem, dir, segment string) (string, bool) {
+	if segment == "" {
+		return "", false
+	}
+	f, err := root.Open(dir)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+	entries, err := f.Readdir(-1)
+	if err != nil {
+		return "", false
+	}
+	want := norm.NFC.String(segment)
+	var normalized, folded []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == segment {
+			return name, true
+		}
+		nfc := norm.NFC.String(name)
+		if nfc == want {
+			normalized = append(normalized, name)
+		} else if strings.EqualFold(nfc, want) {
+			folded = append(folded, name)
+		}
+	}
+	switch {
+	case len(normalized) == 1:
+		return normalized[0], true
+	case len(normalized) == 0 && len(folded) == 1:
+		return folded[0], true
+	}
+	return "", false
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// foldingFS opens names ignoring case, the way macOS and Windows do.
+type foldingFS struct {
+	http.Dir
+}
+
+func (fs foldingFS) Open(name string) (http.File, error) {
+	resolved := "/"
+	for _, segment := range strings.FieldsFunc(name, func(r rune) bool { return r == '/' }) {
+		entries, err := os.ReadDir(filepath.Join(string(fs.Dir), filepath.FromSlash(resolved)))
+		if err != nil {
+			return nil, err
+		}
+		found := ""
+		for _, entry := range entries {
+			if strings.EqualFold(entry.Name(), segment) {
+				found = entry.Name()
+			}
+		}
+		if found == "" {
+			return nil, os.ErrNotExist
+		}
+		resolved = path.Join(resolved, found)
+	}
+	return fs.Dir.Open(resolved)
+}
+
+func TestCanonicalPath(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "docs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "docs", "README.md"), []byte("readme"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		root   http.FileSystem
+		target string
+		want   string
+	}{
+		{foldingFS{http.Dir(dir)}, "/DOCS/readme.md?x=1", "/docs/README.md?x=1"},
+		{foldingFS{http.Dir(dir)}, "/docs/README.md", ""},
+		{http.Dir(dir), "/DOCS/readme.md", ""},
+		{http.Dir(dir), "/docs/README.md", ""},
+	}
+	for _, tt := range tests {
+		handler := canonicalPathHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), tt.root)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", tt.target, nil))
+		if got := w.Header().Get("Location"); got != tt.want {
+			t.Errorf("%T %s: redirected to %q, want %q", tt.root, tt.target, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"hash"
+	"net/http"
+)
+
+var (
+	checksumTrailerFlag = flag.Bool("checksum-trailer", false, "(optional) -checksum-trailer Sends the SHA-256 of full 200 responses as an X-Content-SHA256 trailer, unless they have a Content-Length")
+)
+
+const checksumTrailer = "X-Content-SHA256"
+
+// checksumHandler hashes the body of each full 200 response as it streams
+// and sends the digest as a trailer once the handler is done.
+func checksumHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := newChecksumObserver(w, r)
+		handler.ServeHTTP(c, r)
+		if c.hash != nil {
+			w.Header().Set(checksumTrailer, hex.EncodeToString(c.hash.Sum(nil)))
+		}
+	})
+}
+
+// checksumObserver starts hashing on WriteHeader when a trailer can still
+// reach the client. net/http drops trailers from responses that carry a
+// Content-Length: HTTP/1.1 only has room for them in chunked bodies, and
+// HTTP/2 ends the stream as soon as the declared length is written.
+type checksumObserver struct {
+	*headerObserver
+	hash hash.Hash
+}
+
+func newChecksumObserver(w http.ResponseWriter, r *http.Request) *checksumObserver {
+	c := &checksumObserver{}
+	c.headerObserver = &headerObserver{ResponseWriter: w, beforeHeader: func(code int) int {
+		h := w.Header()
+		if code == http.StatusOK && r.Method != http.MethodHead && h.Get("Content-Length") == "" {
+			h.Add("Trailer", checksumTrailer)
+			c.hash = sha256.New()
+		}
+		return code
+	}}
+	return c
+}
+
+func (c *checksumObserver) Write(p []byte) (int, error) {
+	n, err := c.headerObserver.Write(p)
+	if c.hash != nil {
+		c.hash.Write(p[:n])
+	}
+	return n, err
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"compress/gzip"
+	"flag"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+var (
+	gzipFlag            = flag.Bool("gzip", false, "(optional) -gzip Compresses responses with gzip for clients that accept it")
+	noCompressTypesFlag = flag.String("no-compress-types", "image/*,video/*,audio/*,application/pdf,application/zip,application/gzip,application/x-gzip,application/x-bzip2,application/x-xz,application/x-7z-compressed,application/vnd.rar,.zip,.gz,.tgz,.bz2,.xz,.7z,.rar,.pdf", "(optional) -no-compress-types Comma separated MIME types (image/* style wildcards allowed) or .extensions that are never compressed")
+	compressMinSizeFlag = flag.Int64("compress-min-size", 1024, "(optional) -compress-min-size Responses with a smaller Content-Length are sent uncompressed")
+	noCompressTypes     []string
+)
+
+func parseNoCompressTypes(value string) []string {
+	var types []string
+	for _, t := range strings.Split(value, ",") {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t != "" {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// compressible reports whether a response for urlPath with contentType is
+// worth compressing, i.e. it matches nothing in -no-compress-types.
+func compressible(urlPath, contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	ext := strings.ToLower(path.Ext(urlPath))
+	for _, t := range noCompressTypes {
+		switch {
+		case strings.HasPrefix(t, "."):
+			if t == ext {
+				return false
+			}
+		case strings.HasSuffix(t, "/*"):
+			if strings.HasPrefix(mediaType, strings.TrimSuffix(t, "*")) {
+				return false
+			}
+		case t == mediaType:
+			return false
+		}
+	}
+	return true
+}
+
+// acceptsGzip reports whether the Accept-Encoding header allows gzip.
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !ok {
+			return true
+		}
+		weight, err := strconv.ParseFloat(q, 64)
+		return err == nil && weight > 0
+	}
+	return false
+}
+
+// savesData reports whether the client sent Save-Data: on. Such clients
+// are often low-power devices that would rather not spend CPU on gunzip.
+func savesData(r *http.Request) bool {
+	return strings.EqualFold(strings.TrimSpace(r.Header.Get("Save-Data")), "on")
+}
+
+func gzipHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		g := newGzipObserver(w, r)
+		defer g.Close()
+		handler.ServeHTTP(g, r)
+	})
+}
+
+// gzipObserver decides on the first WriteHeader whether to compress, once
+// the wrapped handler has set Content-Type and Content-Length. Partial
+// (206) and already encoded responses always pass through untouched.
+type gzipObserver struct {
+	*headerObserver
+	r  *http.Request
+	gz *gzip.Writer
+}
+
+func newGzipObserver(w http.ResponseWriter, r *http.Request) *gzipObserver {
+	g := &gzipObserver{r: r}
+	g.headerObserver = &headerObserver{ResponseWriter: w, beforeHeader: func(code int) int {
+		if g.shouldCompress(code) {
+			h := w.Header()
+			h.Del("Content-Length")
+			h.Del("Accept-Ranges")
+			h.Set("Content-Encoding", "gzip")
+			if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				h.Set("ETag", "W/"+etag)
+			}
+			g.gz = gzip.NewWriter(w)
+		}
+		return code
+	}}
+	return g
+}
+
+func (g *gzipObserver) shouldCompress(code int) bool {
+	h := g.Header()
+	if h.Get("Content-Encoding") != "" || !compressible(g.r.URL.Path, h.Get("Content-Type")) {
+		return false
+	}
+	// HEAD, 206 and 304 responses are never compressed themselves but
+	// stand for a body that could have been, so caches need the Vary on
+	// them too.
+	switch code {
+	case http.StatusOK, http.StatusPartialContent, http.StatusNotModified:
+		addVary(h, "Accept-Encoding", "Save-Data")
+	}
+	if code != http.StatusOK || g.r.Method == http.MethodHead {
+		return false
+	}
+	if g.r.URL.Query().Get("nocompress") == "1" || savesData(g.r) || !acceptsGzip(g.r) {
+		return false
+	}
+	if length, err := strconv.ParseInt(h.Get("Content-Length"), 10, 64); err == nil && length < *compressMinSizeFlag {
+		return false
+	}
+	return true
+}
+
+func (g *gzipObserver) Write(p []byte) (int, error) {
+	if !g.wroteHeader && g.Header().Get("Content-Type") == "" {
+		g.Header().Set("Content-Type", http.DetectContentType(p))
+	}
+	if g.gz == nil {
+		return g.headerObserver.Write(p)
+	}
+	return g.gz.Write(p)
+}
+
+// Flush pushes out what the gzip writer holds so far along with the
+// response, at some cost in compression.
+func (g *gzipObserver) Flush() {
+	if !g.wroteHeader {
+		g.WriteHeader(http.StatusOK)
+	}
+	if g.gz != nil {
+		g.gz.Flush()
+	}
+	flushResponse(g.ResponseWriter)
+}
+
+func (g *gzipObserver) Close() error {
+	if g.gz == nil {
+		return nil
+	}
+	return g.gz.Close()
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestGzipVary(t *testing.T) {
+	*gzipFlag = true
+	varyHeaders = []string{"Cookie"}
+	t.Cleanup(func() {
+		*gzipFlag = false
+		varyHeaders = nil
+	})
+	site := newTestSite(t, map[string]string{"page.txt": strings.Repeat("compress me ", 512)})
+
+	tests := []struct {
+		method         string
+		acceptEncoding string
+		encoding       string
+	}{
+		{http.MethodGet, "gzip", "gzip"},
+		{http.MethodGet, "", ""},
+		{http.MethodHead, "gzip", ""},
+	}
+	for _, tt := range tests {
+		w := site.get(tt.method, "/page.txt", map[string]string{"Accept-Encoding": tt.acceptEncoding})
+		if got := w.Header().Get("Content-Encoding"); got != tt.encoding {
+			t.Errorf("%s with Accept-Encoding %q: Content-Encoding %q, want %q", tt.method, tt.acceptEncoding, got, tt.encoding)
+		}
+		if got := w.Header().Values("Vary"); len(got) != 1 || got[0] != "Cookie, Accept-Encoding, Save-Data" {
+			t.Errorf("%s with Accept-Encoding %q: Vary %q, want Accept-Encoding listed once after Cookie", tt.method, tt.acceptEncoding, got)
+		}
+	}
+}
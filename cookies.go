@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"net/http"
+	"strings"
+)
+
+var (
+	cookieSecureFlag   = flag.Bool("cookie-secure", false, "(optional) -cookie-secure Adds Secure to every Set-Cookie sent over TLS that lacks it")
+	cookieSameSiteFlag = flag.String("cookie-samesite", "", "(optional) -cookie-samesite Strict, Lax or None, added to every Set-Cookie without a SameSite attribute")
+)
+
+func parseCookieSameSite(value string) (string, error) {
+	switch strings.ToLower(value) {
+	case "":
+		return "", nil
+	case "strict":
+		return "Strict", nil
+	case "lax":
+		return "Lax", nil
+	case "none":
+		return "None", nil
+	}
+	return "", errors.New("[ERROR] Invalid -cookie-samesite, expected Strict, Lax or None: " + value)
+}
+
+// cookieHandler hardens the cookies in a response, whether a template or
+// -upstream set them, right before the header goes out.
+func cookieHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secure := *cookieSecureFlag && r.TLS != nil
+		// Responses without cookies pass through untouched.
+		handler.ServeHTTP(&headerObserver{ResponseWriter: w, beforeHeader: func(code int) int {
+			cookies := w.Header()["Set-Cookie"]
+			for i, cookie := range cookies {
+				cookies[i] = hardenCookie(cookie, secure, *cookieSameSiteFlag)
+			}
+			return code
+		}}, r)
+	})
+}
+
+// hardenCookie appends Secure and SameSite to a Set-Cookie value unless it
+// already has them. Attributes the cookie sets itself are never changed.
+func hardenCookie(cookie string, secure bool, sameSite string) string {
+	hasSecure, hasSameSite := false, false
+	attrs := strings.Split(cookie, ";")
+	for _, attr := range attrs[1:] {
+		name, _, _ := strings.Cut(strings.TrimSpace(attr), "=")
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "secure":
+			hasSecure = true
+		case "samesite":
+			hasSameSite = true
+		}
+	}
+	if secure && !hasSecure {
+		cookie += "; Secure"
+	}
+	if sameSite != "" && !hasSameSite {
+		cookie += "; SameSite=" + sameSite
+	}
+	return cookie
+}
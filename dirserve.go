@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"html"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	noDirRedirectFlag = flag.Bool("no-dir-redirect", false, "(optional) -no-dir-redirect Serves /dir and /index.html directly instead of redirecting to /dir/ and /")
+	headTagPattern    = regexp.MustCompile(`(?i)<head(\s[^>]*)?>`)
+	basePattern       = regexp.MustCompile(`(?i)<base[\s>]`)
+)
+
+// noDirRedirectHandler serves files and directory indexes at whatever path
+// they were requested under, where http.FileServer would answer with a
+// redirect. Directories without an index.html, and anything that cannot be
+// opened, are left to handler.
+func noDirRedirectHandler(handler http.Handler, root http.FileSystem) http.Handler {
+	base := servedPathBase(root)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean("/" + r.URL.Path)
+		f, err := root.Open(name)
+		if err != nil {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		if !info.IsDir() {
+			noteServedPath(r, base, name)
+			http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+			return
+		}
+
+		index, err := root.Open(path.Join(name, "index.html"))
+		if err != nil {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		defer index.Close()
+		indexInfo, err := index.Stat()
+		if err != nil || indexInfo.IsDir() {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		noteServedPath(r, base, path.Join(name, "index.html"))
+		if strings.HasSuffix(r.URL.Path, "/") {
+			http.ServeContent(w, r, "index.html", indexInfo.ModTime(), index)
+			return
+		}
+
+		// Served at /dir the browser would resolve relative links against
+		// /, so the page is told where it really lives.
+		src, err := io.ReadAll(index)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		page := injectBaseHref(src, *basePathFlag+name+"/")
+		http.ServeContent(w, r, "index.html", indexInfo.ModTime(), bytes.NewReader(page))
+	})
+}
+
+// injectBaseHref adds a <base href> right after the opening <head> tag, or
+// at the top of the page when it has none. A page that sets its own base is
+// left alone.
+func injectBaseHref(page []byte, href string) []byte {
+	if basePattern.Match(page) {
+		return page
+	}
+	base := []byte(`<base href="` + html.EscapeString(href) + `">`)
+	loc := headTagPattern.FindIndex(page)
+	if loc == nil {
+		return append(base, page...)
+	}
+	out := make([]byte, 0, len(page)+len(base))
+	out = append(out, page[:loc[1]]...)
+	out = append(out, base...)
+	return append(out, page[loc[1]:]...)
+}
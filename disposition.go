@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+var (
+	forceDownloadFlag  = flag.String("force-download", "", "(optional) -force-download Comma separated .extensions, or * for all files, sent with Content-Disposition: attachment so browsers save them")
+	inlineFlag         = flag.String("inline", "", "(optional) -inline Comma separated .extensions, or * for all files, sent with Content-Disposition: inline, taking precedence over -force-download")
+	forceDownloadTypes []string
+	inlineTypes        []string
+)
+
+// parseExtensionList splits a comma separated list of extensions, keeping
+// * as a wildcard and adding a leading dot where it is missing.
+func parseExtensionList(value string) []string {
+	var exts []string
+	for _, ext := range strings.Split(value, ",") {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
+		}
+		if ext != "*" && !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		exts = append(exts, ext)
+	}
+	return exts
+}
+
+func matchExtension(exts []string, urlPath string) bool {
+	ext := strings.ToLower(path.Ext(urlPath))
+	for _, e := range exts {
+		if e == "*" || e == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// dispositionHandler picks inline or attachment for the requested file name
+// and leaves the header to be set once the response turns out to be a file.
+func dispositionHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Base(r.URL.Path)
+		if strings.HasSuffix(r.URL.Path, "/") || name == "/" || name == "." {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		disposition := ""
+		switch {
+		case matchExtension(inlineTypes, name):
+			disposition = "inline"
+		case matchExtension(forceDownloadTypes, name):
+			disposition = "attachment"
+		default:
+			handler.ServeHTTP(w, r)
+			return
+		}
+		value := mime.FormatMediaType(disposition, map[string]string{"filename": name})
+		// Only 200 and 206 get the header, so errors and redirects go
+		// out without one.
+		handler.ServeHTTP(&headerObserver{ResponseWriter: w, beforeHeader: func(code int) int {
+			if code == http.StatusOK || code == http.StatusPartialContent {
+				w.Header().Set("Content-Disposition", value)
+			}
+			return code
+		}}, r)
+	})
+}
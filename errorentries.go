@@ -0,0 +1,22 @@
+package main
+
+import (
+	"flag"
+)
+
+var (
+	errorLogFileFlag = flag.String("error-log-file", "", "(optional) -error-log-file File that access log entries with a 4xx or 5xx status are also written to, in the same format")
+	errorAccessLog   *logFile
+)
+
+// setupErrorAccessLog opens -error-log-file and registers it for reopening
+// on SIGHUP.
+func setupErrorAccessLog() error {
+	f, err := openLogFile(*errorLogFileFlag, logFileMode, logDirMode)
+	if err != nil {
+		return err
+	}
+	hangupFiles = append(hangupFiles, f)
+	errorAccessLog = f
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"log"
+	"os"
+)
+
+var (
+	errorLogFlag       = flag.String("error-log", "", "(optional) -error-log File for net/http's own errors such as failed TLS handshakes, or off to drop them. Defaults to stderr, never the access log")
+	errorLogBenignFlag = flag.Bool("error-log-benign", false, "(optional) -error-log-benign Keeps broken pipe and connection reset errors, which are dropped by default")
+	// serverErrorLog is set as ErrorLog on every http.Server.
+	serverErrorLog *log.Logger
+)
+
+// benignErrors are the errors clients cause by going away mid response.
+var benignErrors = [][]byte{
+	[]byte("broken pipe"),
+	[]byte("connection reset by peer"),
+}
+
+// setupErrorLog builds the logger net/http reports its internal errors to,
+// kept apart from the standard logger since that one may write to the
+// access log.
+func setupErrorLog() error {
+	var w io.Writer = os.Stderr
+	switch *errorLogFlag {
+	case "":
+	case "off":
+		w = io.Discard
+	default:
+		f, err := openLogFile(*errorLogFlag, logFileMode, logDirMode)
+		if err != nil {
+			return err
+		}
+		hangupFiles = append(hangupFiles, f)
+		w = f
+	}
+	if !*errorLogBenignFlag {
+		w = &benignFilter{w: w}
+	}
+	serverErrorLog = log.New(w, "", log.Flags())
+	return nil
+}
+
+// benignFilter drops log lines mentioning a benign error. log.Logger hands
+// it exactly one line per Write.
+type benignFilter struct {
+	w io.Writer
+}
+
+func (f *benignFilter) Write(p []byte) (int, error) {
+	for _, benign := range benignErrors {
+		if bytes.Contains(p, benign) {
+			return len(p), nil
+		}
+	}
+	return f.w.Write(p)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	delayFlag       = flag.Duration("delay", 0, "(optional) -delay TESTING ONLY. Waits this long before serving every request, to simulate a slow network")
+	delayJitterFlag = flag.Duration("delay-jitter", 0, "(optional) -delay-jitter TESTING ONLY. Adds a random extra wait of up to this much to -delay")
+	errorRateFlag   = flag.Float64("error-rate", 0, "(optional) -error-rate TESTING ONLY. Fraction of requests, e.g. 0.1, answered with a 5xx instead of being served")
+	errorCodeFlag   = flag.Int("error-code", 0, "(optional) -error-code Status sent by -error-rate, 0 picks one of 500, 502, 503 and 504 at random")
+	benchmarkFlag   = flag.Int("benchmark", 0, "(optional) -benchmark TESTING ONLY. Answers every request with this many bytes from memory instead of serving files, and logs nothing, to load test the server itself")
+)
+
+// injectedCodes are picked from when -error-code is not set.
+var injectedCodes = []int{
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// delayHandler holds every request for -delay plus up to -delay-jitter
+// before serving it. A client that gives up meanwhile releases the wait.
+func delayHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		wait := *delayFlag
+		if *delayJitterFlag > 0 {
+			wait += rand.N(*delayJitterFlag)
+		}
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			handler.ServeHTTP(w, r)
+		case <-r.Context().Done():
+		}
+	})
+}
+
+// errorRateHandler fails an -error-rate fraction of requests with a 5xx
+// and logs each one, so injected errors can be told apart from real ones.
+func errorRateHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rand.Float64() >= *errorRateFlag {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		code := *errorCodeFlag
+		if code == 0 {
+			code = injectedCodes[rand.IntN(len(injectedCodes))]
+		}
+		log.Printf("[FAULT] Injected %d for %s %s", code, r.Method, r.RequestURI)
+		http.Error(w, http.StatusText(code), code)
+	})
+}
+
+// benchmarkHandler answers every request with the same -benchmark bytes,
+// held in memory, so load tests measure the server and its middlewares
+// without the file system.
+func benchmarkHandler() http.Handler {
+	body := bytes.Repeat([]byte("x"), *benchmarkFlag)
+	length := strconv.Itoa(len(body))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", length)
+		w.Write(body)
+	})
+}
+
+func checkFaultFlags() error {
+	if *delayFlag < 0 || *delayJitterFlag < 0 {
+		return errors.New("[ERROR] -delay and -delay-jitter must not be negative")
+	}
+	if *errorRateFlag < 0 || *errorRateFlag > 1 {
+		return errors.New("[ERROR] -error-rate must be between 0 and 1")
+	}
+	if *benchmarkFlag < 0 {
+		return errors.New("[ERROR] -benchmark must not be negative")
+	}
+	if *errorCodeFlag != 0 && (*errorCodeFlag < 500 || *errorCodeFlag > 599) {
+		return errors.New("[ERROR] -error-code must be a 5xx status")
+	}
+	return nil
+}
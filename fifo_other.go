@@ -0,0 +1,18 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+var errPipeFull = errors.New("pipe full")
+
+func openFifo(path string) (*os.File, error) {
+	return nil, errors.New("[ERROR] Logging to a named pipe is only supported on Unix")
+}
+
+func writeNonBlocking(file *os.File, p []byte) (int, error) {
+	return file.Write(p)
+}
@@ -0,0 +1,46 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+var errPipeFull = errors.New("pipe full")
+
+// openFifo opens a named pipe for writing without waiting for a reader. It
+// returns a nil file and no error while nobody has the pipe open to read.
+func openFifo(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if errors.Is(err, syscall.ENXIO) {
+		return nil, nil
+	}
+	return file, err
+}
+
+// writeNonBlocking makes a single write(2) to file, returning errPipeFull
+// rather than waiting when the pipe has no room. Going through the raw
+// descriptor keeps the runtime poller from parking the caller until the
+// reader catches up. Entries up to PIPE_BUF bytes are written whole or not
+// at all.
+func writeNonBlocking(file *os.File, p []byte) (int, error) {
+	conn, err := file.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	var writeErr error
+	err = conn.Write(func(fd uintptr) bool {
+		n, writeErr = syscall.Write(int(fd), p)
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	if writeErr == syscall.EAGAIN {
+		return 0, errPipeFull
+	}
+	return max(n, 0), writeErr
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"strings"
+)
+
+// checkDuplicateFlags walks args the way flag.Parse does and refuses a
+// flag given twice, of which flag would silently keep the last value.
+// Repeatable flags are left alone.
+func checkDuplicateFlags(args []string) error {
+	seen := map[string]bool{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if len(arg) < 2 || arg[0] != '-' || arg == "--" {
+			return nil
+		}
+		name, _, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		f := flag.Lookup(name)
+		if f == nil {
+			return nil
+		}
+		if _, repeatable := f.Value.(*stringList); !repeatable {
+			if seen[name] {
+				return errors.New("[ERROR] -" + name + " is given more than once, only the last value would be used")
+			}
+			seen[name] = true
+		}
+		if boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool }); !hasValue && !(ok && boolFlag.IsBoolFlag()) {
+			i++
+		}
+	}
+	return nil
+}
+
+// checkIgnoredFlags refuses flags that only take effect together with
+// another one that is missing, so they are not silently ignored.
+func checkIgnoredFlags() error {
+	if (*certChainPathFlag == "") != (*certPrivKeyFlag == "") {
+		return errors.New("[ERROR] -c and -k must be given together, with only one of them the server would run without TLS")
+	}
+	if *redirectHttpsFlag && !isTLS {
+		return errors.New("[ERROR] -r requires a certificate")
+	}
+	if *redirectAddrFlag != ":80" && !*redirectHttpsFlag {
+		return errors.New("[ERROR] -redirect-addr requires -r")
+	}
+	if *gelfUDPFlag != "" && *logFormatFlag != "gelf" {
+		return errors.New("[ERROR] -gelf-udp requires -format gelf")
+	}
+	if *renderCacheFlag && *renderExtFlag == "" {
+		return errors.New("[ERROR] -render-cache requires -render-ext")
+	}
+	if *errorCodeFlag != 0 && *errorRateFlag == 0 {
+		return errors.New("[ERROR] -error-code requires -error-rate")
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"testing"
+)
+
+// setFlags sets the named flags for the rest of the test and restores
+// their previous values afterwards. Not for repeatable flags, whose Set
+// appends.
+func setFlags(t *testing.T, values map[string]string) {
+	t.Helper()
+	for name, value := range values {
+		f := flag.Lookup(name)
+		if f == nil {
+			t.Fatalf("no flag -%s", name)
+		}
+		old := f.Value.String()
+		if err := f.Value.Set(value); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { f.Value.Set(old) })
+	}
+}
+
+func TestCheckDuplicateFlags(t *testing.T) {
+	tests := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"-p", "8080", "-d", "www"}, ""},
+		{[]string{"-p", "8080", "-p", "9090"}, "-p is given more than once"},
+		{[]string{"-p=8080", "--p", "9090"}, "-p is given more than once"},
+		{[]string{"-gzip", "-gzip=false"}, "-gzip is given more than once"},
+		{[]string{"-gzip", "-d", "www"}, ""},
+		{[]string{"-host", "a.example", "-host", "b.example"}, ""},
+		{[]string{"-log", "stderr:tab", "-log", "a.json:json"}, ""},
+		{[]string{"-d", "-d"}, ""},
+		{[]string{"-p", "8080", "--", "-p", "9090"}, ""},
+		{[]string{"-p", "8080", "extra", "-p", "9090"}, ""},
+		{[]string{"-unknown", "-p", "1", "-p", "2"}, ""},
+	}
+	for _, tt := range tests {
+		err := checkDuplicateFlags(tt.args)
+		if tt.err == "" && err != nil {
+			t.Errorf("%q: unexpected error %v", tt.args, err)
+		}
+		if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%q: error %v, want one containing %q", tt.args, err, tt.err)
+		}
+	}
+}
+
+func TestCheckIgnoredFlags(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags map[string]string
+		tls   bool
+		err   string
+	}{
+		{"defaults", nil, false, ""},
+		{"certificate", map[string]string{"c": "cert.pem", "k": "key.pem"}, true, ""},
+		{"-c alone", map[string]string{"c": "cert.pem"}, false, "-c and -k must be given together"},
+		{"-k alone", map[string]string{"k": "key.pem"}, false, "-c and -k must be given together"},
+		{"-r without TLS", map[string]string{"r": "true"}, false, "-r requires a certificate"},
+		{"-r with TLS", map[string]string{"r": "true", "c": "cert.pem", "k": "key.pem"}, true, ""},
+		{"-redirect-addr without -r", map[string]string{"redirect-addr": ":8080"}, false, "-redirect-addr requires -r"},
+		{"-redirect-addr with -r", map[string]string{"redirect-addr": ":8080", "r": "true", "c": "cert.pem", "k": "key.pem"}, true, ""},
+		{"-gelf-udp without -format gelf", map[string]string{"gelf-udp": "localhost:12201"}, false, "-gelf-udp requires -format gelf"},
+		{"-gelf-udp with -format gelf", map[string]string{"gelf-udp": "localhost:12201", "format": "gelf"}, false, ""},
+		{"-render-cache without -render-ext", map[string]string{"render-cache": "true"}, false, "-render-cache requires -render-ext"},
+		{"-render-cache with -render-ext", map[string]string{"render-cache": "true", "render-ext": ".tmpl"}, false, ""},
+		{"-error-code without -error-rate", map[string]string{"error-code": "502"}, false, "-error-code requires -error-rate"},
+		{"-error-code with -error-rate", map[string]string{"error-code": "502", "error-rate": "0.1"}, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlags(t, tt.flags)
+			tls := isTLS
+			isTLS = tt.tls
+			t.Cleanup(func() { isTLS = tls })
+
+			err := checkIgnoredFlags()
+			if tt.err == "" && err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("error %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"errors"
+	"flag"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	gelfUDPFlag = flag.String("gelf-udp", "", "(optional) -gelf-udp host:port of a Graylog GELF UDP input. With -format gelf, messages go there instead of the log file")
+	gelfConn    net.Conn
+	gelfHost    string
+)
+
+const (
+	// gelfChunkSize keeps each datagram under a typical 1500 byte MTU.
+	gelfChunkSize = 1420
+	gelfMaxChunks = 128
+)
+
+// gelfMessage maps a RequestLog onto GELF 1.1: the required fields plus
+// every RequestLog field as an underscore prefixed additional field.
+func gelfMessage(requestLog RequestLog) map[string]any {
+	message := map[string]any{
+		"version":            "1.1",
+		"host":               gelfHost,
+		"short_message":      requestLog.Method + " " + requestLog.URL + " " + strconv.Itoa(requestLog.Status),
+		"timestamp":          dateTimeSeconds(requestLog.DateTime),
+		"level":              6,
+		"_remote_addr":       requestLog.RemoteAddr,
+		"_url":               requestLog.URL,
+		"_request_host":      requestLog.Host,
+		"_user_agent":        requestLog.UserAgent,
+		"_referer":           requestLog.Referer,
+		"_method":            requestLog.Method,
+		"_request_uri":       requestLog.RequestURI,
+		"_protocol":          requestLog.Protocol,
+		"_status":            requestLog.Status,
+		"_written":           requestLog.Written,
+		"_content_length":    requestLog.ContentLength,
+		"_time_taken":        requestLog.TimeTaken,
+		"_client_closed":     requestLog.ClientClosed,
+		"_is_bot":            requestLog.IsBot,
+		"_upstream":          requestLog.Upstream,
+		"_transfer_encoding": requestLog.TransferEncoding,
+	}
+	if requestLog.TraceId != "" {
+		message["_trace_id"] = requestLog.TraceId
+	}
+	if requestLog.ServedPath != "" {
+		message["_served_path"] = requestLog.ServedPath
+	}
+	if requestLog.GRPCStatus != "" {
+		message["_grpc_status"] = requestLog.GRPCStatus
+	}
+	for key, value := range requestLog.Labels {
+		message["_label_"+key] = value
+	}
+	for name, value := range requestLog.Headers {
+		message["_header_"+strings.ToLower(strings.ReplaceAll(name, "-", "_"))] = value
+	}
+	return message
+}
+
+// setupGelf resolves the host name reported in every message and dials the
+// -gelf-udp target when one is given.
+func setupGelf() error {
+	host, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+	gelfHost = host
+	if *gelfUDPFlag == "" {
+		return nil
+	}
+	gelfConn, err = net.Dial("udp", *gelfUDPFlag)
+	return err
+}
+
+// gelfWarnInterval is how often failed -gelf-udp sends are reported.
+const gelfWarnInterval = time.Minute
+
+// gelfDrops counts the messages -gelf-udp failed to send since they were
+// last reported.
+var gelfDrops struct {
+	mu       sync.Mutex
+	count    int
+	lastWarn time.Time
+}
+
+// gelfUDPWriter sends each message written to it to -gelf-udp. A failed
+// send drops the message rather than failing the write, since an access
+// log error stops the server and Graylog being away should not.
+type gelfUDPWriter struct{}
+
+func (gelfUDPWriter) Write(p []byte) (int, error) {
+	err := sendGelfUDP(bytes.TrimSuffix(p, []byte("\n")))
+	if err != nil {
+		dropGelfMessage(err)
+	}
+	return len(p), nil
+}
+
+// dropGelfMessage counts a message lost to err and prints a warning with
+// the count at most once per gelfWarnInterval.
+func dropGelfMessage(err error) {
+	gelfDrops.mu.Lock()
+	defer gelfDrops.mu.Unlock()
+	gelfDrops.count++
+	if time.Since(gelfDrops.lastWarn) < gelfWarnInterval {
+		return
+	}
+	print("[WARN] Dropped " + strconv.Itoa(gelfDrops.count) + " GELF messages to " + *gelfUDPFlag + ", last error: " + err.Error())
+	gelfDrops.count = 0
+	gelfDrops.lastWarn = time.Now()
+}
+
+// sendGelfUDP gzips message and sends it in one datagram, or split into GELF
+// chunks when it does not fit.
+func sendGelfUDP(message []byte) error {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write(message)
+	gz.Close()
+	payload := compressed.Bytes()
+
+	if len(payload) <= gelfChunkSize {
+		_, err := gelfConn.Write(payload)
+		return err
+	}
+
+	count := (len(payload) + gelfChunkSize - 1) / gelfChunkSize
+	if count > gelfMaxChunks {
+		return errors.New("[ERROR] GELF message too large to send over UDP")
+	}
+	id := make([]byte, 8)
+	rand.Read(id)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * gelfChunkSize
+		if end > len(payload) {
+			end = len(payload)
+		}
+		chunk := make([]byte, 0, 12+end-i*gelfChunkSize)
+		chunk = append(chunk, 0x1e, 0x0f)
+		chunk = append(chunk, id...)
+		chunk = append(chunk, byte(i), byte(count))
+		chunk = append(chunk, payload[i*gelfChunkSize:end]...)
+		_, err := gelfConn.Write(chunk)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+)
+
+var (
+	rejectTraversalFlag = flag.Bool("reject-traversal", false, "(optional) -reject-traversal Rejects paths containing .. segments or null bytes with 400 and logs them as possible attacks")
+	maxBodySizeFlag     = flag.Int64("max-body-size", 0, "(optional) -max-body-size Requests with a larger body get 413 Request Entity Too Large, 0 means no limit")
+	mask403Flag         = flag.Bool("mask-403", false, "(optional) -mask-403 Sends 404 Not Found instead of 403 Forbidden so hidden paths look missing. The access log keeps the 403")
+	maxPathLenFlag      = flag.Int("max-path-len", 0, "(optional) -max-path-len Requests with a longer URL path get 414 URI Too Long, 0 means no limit")
+)
+
+// maxPathLenHandler rejects requests whose path exceeds -max-path-len before
+// they reach the file server.
+func maxPathLenHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.URL.Path) > *maxPathLenFlag {
+			http.Error(w, http.StatusText(http.StatusRequestURITooLong), http.StatusRequestURITooLong)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// traversalHandler rejects paths that try to climb out of the served
+// directory or smuggle a null byte. cleanPathHandler would redirect these
+// anyway; this makes the probe visible in the logs.
+func traversalHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "\x00") || hasDotDotSegment(r.URL.Path) {
+			log.Printf("[WARN] Possible path traversal from %s: %q", r.RemoteAddr, r.RequestURI)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// cleanPathHandler redirects requests for paths with . or .. segments or
+// repeated slashes to the cleaned path, as a ServeMux would, so every
+// prefix and glob check further in sees the path FileServer serves.
+// OPTIONS * has no path to clean.
+func cleanPathHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cleaned := cleanPath(r.URL.Path)
+		if cleaned == r.URL.Path || r.RequestURI == "*" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		u := *r.URL
+		u.Path = cleaned
+		u.RawPath = ""
+		http.Redirect(w, r, u.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+// cleanPath is path.Clean rooted at / that keeps a trailing slash.
+func cleanPath(p string) string {
+	cleaned := path.Clean("/" + p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+func hasDotDotSegment(p string) bool {
+	for _, segment := range strings.FieldsFunc(p, func(c rune) bool { return c == '/' || c == '\\' }) {
+		if segment == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// maxBodySizeHandler refuses a declared Content-Length over -max-body-size up
+// front and caps the body of every other request, so a handler reading it
+// gets an error instead of buffering an unbounded upload.
+func maxBodySizeHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > *maxBodySizeFlag {
+			w.Header().Set("Connection", "close")
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, *maxBodySizeFlag)
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// mask403Handler answers 404 wherever handler answers 403, so a prober
+// cannot tell a forbidden path from a missing one. It wraps logHandler,
+// which still records the real 403.
+func mask403Handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(newMask403Observer(w), r)
+	})
+}
+
+// mask403Observer swaps a 403 for the plain 404 page http.NotFound sends
+// and drops the body the handler writes after it.
+type mask403Observer struct {
+	*headerObserver
+	masked bool
+}
+
+func newMask403Observer(w http.ResponseWriter) *mask403Observer {
+	o := &mask403Observer{}
+	o.headerObserver = &headerObserver{ResponseWriter: w, beforeHeader: func(code int) int {
+		if code != http.StatusForbidden {
+			return code
+		}
+		o.masked = true
+		h := w.Header()
+		h.Del("Content-Length")
+		h.Set("Content-Type", "text/plain; charset=utf-8")
+		h.Set("X-Content-Type-Options", "nosniff")
+		return http.StatusNotFound
+	}, afterHeader: func() {
+		if o.masked {
+			fmt.Fprintln(w, "404 page not found")
+		}
+	}}
+	return o
+}
+
+func (o *mask403Observer) Write(p []byte) (int, error) {
+	if !o.wroteHeader {
+		o.WriteHeader(http.StatusOK)
+	}
+	if o.masked {
+		return len(p), nil
+	}
+	return o.ResponseWriter.Write(p)
+}
+
+// allowedMethods are the methods the file server answers.
+const allowedMethods = "GET, HEAD, OPTIONS"
+
+// optionsHandler answers the server wide OPTIONS * and OPTIONS for the root
+// with 204 and the methods the server supports instead of letting the file
+// server send content for them. OPTIONS for any other path is left to the
+// rest of the chain, so missing files still get 404 and CORS preflights
+// still reach -upstream.
+func optionsHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodOptions || (r.RequestURI != "*" && r.URL.Path != "/") {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Allow", allowedMethods)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
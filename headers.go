@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+)
+
+var (
+	logHeadersFlag          = flag.String("log-headers", "", "(optional) -log-headers Comma separated request header names, or * for all, added to log entries as Headers. Authorization and Cookie values are redacted")
+	logSensitiveHeadersFlag = flag.Bool("log-sensitive-headers", false, "(optional) -log-sensitive-headers Logs Authorization, Proxy-Authorization and Cookie values instead of redacting them")
+	logHeadersSampleFlag    = flag.Float64("log-headers-sample", 1, "(optional) -log-headers-sample Fraction of requests, between 0 and 1, whose headers are logged with -log-headers")
+	// loggedHeaders holds the canonical -log-headers names, or only "*".
+	loggedHeaders []string
+)
+
+// sensitiveHeaders carry credentials and are redacted unless
+// -log-sensitive-headers is set.
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+}
+
+func parseLoggedHeaders(value string) ([]string, error) {
+	if *logHeadersSampleFlag < 0 || *logHeadersSampleFlag > 1 {
+		return nil, errors.New("[ERROR] -log-headers-sample must be between 0 and 1")
+	}
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "*" {
+			return []string{"*"}, nil
+		}
+		if name != "" {
+			names = append(names, http.CanonicalHeaderKey(name))
+		}
+	}
+	return names, nil
+}
+
+// logHeaders returns the -log-headers of r for its log entry, or nil when
+// the flag is unset or the request was not sampled. Repeated headers are
+// joined with commas.
+func logHeaders(r *http.Request) map[string]string {
+	if len(loggedHeaders) == 0 || rand.Float64() >= *logHeadersSampleFlag {
+		return nil
+	}
+	headers := map[string]string{}
+	add := func(name string, values []string) {
+		if sensitiveHeaders[name] && !*logSensitiveHeadersFlag {
+			headers[name] = "REDACTED"
+			return
+		}
+		headers[name] = strings.Join(values, ", ")
+	}
+	if loggedHeaders[0] == "*" {
+		for name, values := range r.Header {
+			add(name, values)
+		}
+		return headers
+	}
+	for _, name := range loggedHeaders {
+		if values, ok := r.Header[name]; ok {
+			add(name, values)
+		}
+	}
+	return headers
+}
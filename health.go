@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	healthDeepFlag     = flag.Bool("health-deep", false, "(optional) -health-deep Makes /healthz check that the served directories can be read, answering 503 with the error when they cannot")
+	healthSentinelFlag = flag.String("health-sentinel", "", "(optional) -health-sentinel File, relative to each served directory, that -health-deep also reads, e.g. .healthy")
+)
+
+// healthCheckInterval bounds how stale the cached -health-deep result can
+// be, so frequent probes do not each touch the disk.
+const healthCheckInterval = 2 * time.Second
+
+// healthState caches the result of the last deep health check.
+type healthState struct {
+	mu      sync.Mutex
+	checked time.Time
+	err     error
+}
+
+var deepHealth = &healthState{}
+
+func (h *healthState) check() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if time.Since(h.checked) >= healthCheckInterval {
+		h.err = checkServedDirs()
+		h.checked = time.Now()
+	}
+	return h.err
+}
+
+// checkServedDirs lists -d and every -vhost directory and reads the
+// -health-sentinel in each. An -archive is in memory and always healthy.
+func checkServedDirs() error {
+	var dirs []string
+	if *archiveFlag == "" {
+		dirs = append(dirs, *serveDirectoryFlag)
+	}
+	for _, dir := range vhosts {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		err := checkServedDir(dir)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkServedDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Readdirnames(1)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if *healthSentinelFlag == "" {
+		return nil
+	}
+	sentinel := filepath.Join(dir, *healthSentinelFlag)
+	_, err = os.ReadFile(sentinel)
+	if errors.Is(err, os.ErrNotExist) {
+		return errors.New("sentinel " + sentinel + " is missing")
+	}
+	return err
+}
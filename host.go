@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+)
+
+var (
+	hostFlags            stringList
+	allowedHosts         map[string]bool
+	allowMissingHostFlag = flag.Bool("allow-missing-host", false, "(optional) -allow-missing-host Serves HTTP/1.0 requests without a Host header, which otherwise get 400 Bad Request like HTTP/1.1 requests with an empty one")
+)
+
+func init() {
+	flag.Var(&hostFlags, "host", "(optional) -host Host name requests must be addressed to, others get 421 Misdirected Request. Repeatable, all hosts are allowed when unset")
+}
+
+// normalizeHost strips the port and any trailing dot from a Host header and
+// lowercases it, so example.com:443 and Example.com. compare equal.
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+func parseAllowedHosts(values []string) map[string]bool {
+	if len(values) == 0 {
+		return nil
+	}
+	hosts := map[string]bool{}
+	for _, value := range values {
+		hosts[normalizeHost(value)] = true
+	}
+	return hosts
+}
+
+// hostHandler answers 421 to requests whose Host is not one of -host, so
+// the content is not served under names pointed at the server by someone
+// else.
+func hostHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowedHosts[normalizeHost(r.Host)] {
+			log.Printf("[WARN] Rejected request from %s for host %q", r.RemoteAddr, r.Host)
+			http.Error(w, http.StatusText(http.StatusMisdirectedRequest), http.StatusMisdirectedRequest)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// requireHostHandler answers 400 to requests without a Host before any
+// file is looked up. net/http already refuses HTTP/1.1 requests that omit
+// the header, but lets an empty one through, and HTTP/1.0 never needed it.
+func requireHostHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "" || (*allowMissingHostFlag && !r.ProtoAtLeast(1, 1)) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		log.Printf("[WARN] Rejected %s request from %s without a Host header", r.Proto, r.RemoteAddr)
+		http.Error(w, "Missing Host header", http.StatusBadRequest)
+	})
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+)
+
+var (
+	httpsOnlyPathFlags  stringList
+	httpsOnlyPatterns   []string
+	httpsOnlyRejectFlag = flag.Bool("https-only-reject", false, "(optional) -https-only-reject Answers plain HTTP requests for -https-only-path paths with 403 instead of redirecting them")
+)
+
+func init() {
+	flag.Var(&httpsOnlyPathFlags, "https-only-path", "(optional) -https-only-path Path, e.g. /admin, or glob only served over TLS. Plain HTTP requests from -dual are redirected to HTTPS. Repeatable")
+}
+
+func parseHTTPSOnlyPatterns(values []string) ([]string, error) {
+	for _, pattern := range values {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, errors.New("[ERROR] Invalid -https-only-path: " + pattern)
+		}
+	}
+	return values, nil
+}
+
+// matchHTTPSOnly reports whether urlPath matches an -https-only-path glob or
+// lies below one given as a plain path.
+func matchHTTPSOnly(urlPath string) bool {
+	if matchGlobs(httpsOnlyPatterns, urlPath) {
+		return true
+	}
+	for _, pattern := range httpsOnlyPatterns {
+		prefix := strings.TrimSuffix(pattern, "/")
+		if urlPath == prefix || strings.HasPrefix(urlPath, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// httpsOnlyHandler sends plain HTTP requests for -https-only-path paths to
+// redirectHttpsHandler, or refuses them with -https-only-reject, so those
+// paths never travel in the clear even while the rest of the site does.
+func httpsOnlyHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Matched on the path FileServer will serve, so //admin or
+		// /x/../admin cannot slip past an /admin rule.
+		if r.TLS != nil || !matchHTTPSOnly(cleanPath(r.URL.Path)) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		if *httpsOnlyRejectFlag {
+			log.Printf("[WARN] Refused plain HTTP request for %s from %s", r.URL.Path, r.RemoteAddr)
+			http.Error(w, "This page is only available over HTTPS", http.StatusForbidden)
+			return
+		}
+		log.Printf("[INFO] Redirected plain HTTP request for %s from %s to HTTPS", r.URL.Path, r.RemoteAddr)
+		redirectHttpsHandler(w, r)
+	})
+}
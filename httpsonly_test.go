@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPSOnlyHandlerMatchesCleanedPath(t *testing.T) {
+	httpsOnlyPatterns = []string{"/admin", "*.key"}
+	*httpsOnlyRejectFlag = true
+	t.Cleanup(func() {
+		httpsOnlyPatterns = nil
+		*httpsOnlyRejectFlag = false
+	})
+	served := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := httpsOnlyHandler(served)
+
+	tests := []struct {
+		target string
+		want   int
+	}{
+		{"/admin", http.StatusForbidden},
+		{"/admin/secret.txt", http.StatusForbidden},
+		{"//admin/secret.txt", http.StatusForbidden},
+		{"/./admin/secret.txt", http.StatusForbidden},
+		{"/x/../admin/secret.txt", http.StatusForbidden},
+		{"/admin//secret.txt", http.StatusForbidden},
+		{"/./server.key", http.StatusForbidden},
+		{"/administrator", http.StatusOK},
+		{"/public/admin", http.StatusOK},
+		{"/", http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if w.Code != tt.want {
+			t.Errorf("GET %s over HTTP: status %d, want %d", tt.target, w.Code, tt.want)
+		}
+	}
+}
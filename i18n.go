@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	i18nFlag = flag.Bool("i18n", false, "(optional) -i18n Serves page.fr.html instead of page.html, or index.fr.html for a directory, when it exists and the client's Accept-Language prefers fr")
+)
+
+// languageTag matches BCP 47 language tag syntax, which is all a range may
+// contain before it is spliced into a file name.
+var languageTag = regexp.MustCompile(`^[A-Za-z]{1,8}(-[A-Za-z0-9]{1,8})*$`)
+
+// acceptedLanguages returns the lowercased language ranges of an
+// Accept-Language header, most preferred first. Ranges with q=0, the *
+// wildcard, anything that is not a language tag and malformed weights are
+// dropped.
+func acceptedLanguages(header string) []string {
+	type weighted struct {
+		lang string
+		q    float64
+	}
+	var langs []weighted
+	for _, part := range strings.Split(header, ",") {
+		lang, params, _ := strings.Cut(part, ";")
+		lang = strings.ToLower(strings.TrimSpace(lang))
+		if !languageTag.MatchString(lang) {
+			continue
+		}
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			q, err = strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		langs = append(langs, weighted{lang: lang, q: q})
+	}
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+	names := make([]string, len(langs))
+	for i, l := range langs {
+		names[i] = l.lang
+	}
+	return names
+}
+
+// languageCandidates expands ranges such as fr-ca into fr-ca then fr, so a
+// regional preference still finds a plain language variant.
+func languageCandidates(langs []string) []string {
+	var candidates []string
+	seen := map[string]bool{}
+	add := func(lang string) {
+		if !seen[lang] {
+			seen[lang] = true
+			candidates = append(candidates, lang)
+		}
+	}
+	for _, lang := range langs {
+		add(lang)
+		if primary, _, ok := strings.Cut(lang, "-"); ok {
+			add(primary)
+		}
+	}
+	return candidates
+}
+
+// i18nHandler points requests for an .html file, or a directory whose
+// index.html would be served, at the best language variant in root that
+// the client accepts. Without a match the base file is served as usual.
+func i18nHandler(handler http.Handler, root http.FileSystem) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		base := r.URL.Path
+		if strings.HasSuffix(base, "/") {
+			base += "index.html"
+		}
+		if path.Ext(base) != ".html" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		addVary(w.Header(), "Accept-Language")
+		stem := strings.TrimSuffix(base, ".html")
+		for _, lang := range languageCandidates(acceptedLanguages(r.Header.Get("Accept-Language"))) {
+			variant := stem + "." + lang + ".html"
+			// Guards the checks made on the requested path, such as signed
+			// URLs and -https-only-path, against a variant elsewhere.
+			if path.Dir(path.Clean(variant)) != path.Dir(path.Clean(base)) {
+				continue
+			}
+			f, err := root.Open(variant)
+			if err != nil {
+				continue
+			}
+			info, err := f.Stat()
+			f.Close()
+			if err != nil || info.IsDir() {
+				continue
+			}
+			w.Header().Set("Content-Language", lang)
+			r.URL.Path = variant
+			r.URL.RawPath = ""
+			break
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
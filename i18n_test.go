@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestI18nVariant(t *testing.T) {
+	*i18nFlag = true
+	t.Cleanup(func() { *i18nFlag = false })
+	site := newTestSite(t, map[string]string{
+		"index.html":    "en",
+		"index.fr.html": "fr",
+	})
+
+	w := site.get(http.MethodGet, "/", map[string]string{"Accept-Language": "de;q=0.9, fr-CA, en;q=0.5"})
+	if w.Body.String() != "fr" || w.Header().Get("Content-Language") != "fr" {
+		t.Errorf("body %q with Content-Language %q, want the fr variant", w.Body.String(), w.Header().Get("Content-Language"))
+	}
+}
+
+// TestI18nTraversal checks that Accept-Language cannot point a request
+// that passed the signed URL check at a file elsewhere.
+func TestI18nTraversal(t *testing.T) {
+	*i18nFlag = true
+	*signKeyFlag = "secret"
+	t.Cleanup(func() {
+		*i18nFlag = false
+		*signKeyFlag = ""
+	})
+	site := newTestSite(t, map[string]string{
+		"index.html":         "index",
+		"docs/page.html":     "page",
+		"admin/secret.html":  "secret",
+		"docs/page.x.y.html": "odd",
+	})
+
+	tests := []struct {
+		path           string
+		acceptLanguage string
+		body           string
+	}{
+		{"/", "x/../../admin/secret", "index"},
+		{"/", "../admin/secret", "index"},
+		{"/docs/page.html", "x/../../../admin/secret", "page"},
+		{"/docs/page.html", `x\..\..\admin\secret`, "page"},
+		{"/docs/page.html", "x.y", "page"},
+	}
+	for _, tt := range tests {
+		target := signedURL(tt.path, time.Now().Add(time.Hour))
+		w := site.get(http.MethodGet, target, map[string]string{"Accept-Language": tt.acceptLanguage})
+		if w.Code != http.StatusOK || w.Body.String() != tt.body {
+			t.Errorf("GET %s with Accept-Language %q: status %d, body %q, want 200 with %q", tt.path, tt.acceptLanguage, w.Code, w.Body.String(), tt.body)
+		}
+	}
+}
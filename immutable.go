@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// immutableCacheControl lets browsers and CDNs keep a response for a year
+// without ever revalidating it.
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+var (
+	immutablePatternFlags stringList
+	immutablePatterns     []string
+)
+
+func init() {
+	flag.Var(&immutablePatternFlags, "immutable-pattern", "(optional) -immutable-pattern Glob of fingerprinted files, e.g. *.[0-9a-f][0-9a-f][0-9a-f][0-9a-f]*.js, sent with Cache-Control: "+immutableCacheControl+". Repeatable")
+}
+
+func parseImmutablePatterns(values []string) ([]string, error) {
+	for _, pattern := range values {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, errors.New("[ERROR] Invalid -immutable-pattern: " + pattern)
+		}
+	}
+	return values, nil
+}
+
+// immutableHandler marks successful responses for paths matching an
+// -immutable-pattern as cacheable forever. Directories and errors are left
+// alone so a missing asset is not cached as missing.
+func immutableHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/") || !matchGlobs(immutablePatterns, r.URL.Path) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		// Only 200, 206 and 304 responses get the Cache-Control.
+		handler.ServeHTTP(&headerObserver{ResponseWriter: w, beforeHeader: func(code int) int {
+			switch code {
+			case http.StatusOK, http.StatusPartialContent, http.StatusNotModified:
+				w.Header().Set("Cache-Control", immutableCacheControl)
+			}
+			return code
+		}}, r)
+	})
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"strings"
+)
+
+var (
+	logLabelFlags stringList
+	// logLabels are the -log-label pairs added to every log entry.
+	logLabels map[string]string
+)
+
+func init() {
+	flag.Var(&logLabelFlags, "log-label", "(optional) -log-label 'key=value' Constant field added to every JSON, slog, logfmt, GELF and audit log entry, e.g. env=prod. Repeatable")
+}
+
+func parseLogLabels(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	labels := map[string]string{}
+	for _, value := range values {
+		key, label, ok := strings.Cut(value, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " =\"") {
+			return nil, errors.New("[ERROR] Invalid log label, expected key=value: " + value)
+		}
+		labels[key] = label
+	}
+	return labels, nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+var (
+	reusePortFlag           = flag.Bool("reuseport", false, "(optional) -reuseport Set SO_REUSEPORT on listening sockets so several instances can share a port (Linux/BSD only)")
+	tlsHandshakeTimeoutFlag = flag.Duration("tls-handshake-timeout", 10*time.Second, "(optional) -tls-handshake-timeout Drops TLS connections that have not finished the handshake in time, 0 means no limit")
+	tcpKeepAliveFlag        = flag.Duration("tcp-keepalive", 15*time.Second, "(optional) -tcp-keepalive TCP keep-alive probe period for accepted connections, 0 disables")
+	noHTTP2Flag             = flag.Bool("no-http2", false, "(optional) -no-http2 Only negotiates HTTP/1.1 over TLS")
+	http2OnlyFlag           = flag.Bool("http2-only", false, "(optional) -http2-only Only negotiates HTTP/2 over TLS, clients without it cannot connect")
+	tcpNoDelayFlag          = flag.Bool("tcp-nodelay", true, "(optional) -tcp-nodelay Sends small writes straight away. -tcp-nodelay=false enables Nagle's algorithm, trading latency for fewer packets on bulk transfers")
+	backlogFlag             = flag.Int("backlog", 0, "(optional) -backlog Accept queue length for listening sockets, 0 uses the OS default (Linux/BSD only)")
+)
+
+// listen opens a TCP listener on addr with the socket options selected by
+// the -reuseport and -backlog flags applied. Accepted connections get the
+// -tcp-keepalive period. After a restart the socket inherited for addr is
+// reused instead, with the options it was opened with.
+func listen(addr string) (net.Listener, error) {
+	if ln, ok := takeInheritedListener(addr); ok {
+		openListeners = append(openListeners, namedListener{addr: addr, ln: ln})
+		return withNoDelay(ln), nil
+	}
+	lc := net.ListenConfig{Control: controlSocket, KeepAlive: *tcpKeepAliveFlag}
+	if *tcpKeepAliveFlag == 0 {
+		// ListenConfig reads zero as the default and negative as off.
+		lc.KeepAlive = -1
+	}
+	ln, err := lc.Listen(context.Background(), "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if *backlogFlag > 0 {
+		err = setBacklog(ln, *backlogFlag)
+		if err != nil {
+			ln.Close()
+			return nil, err
+		}
+	}
+	openListeners = append(openListeners, namedListener{addr: addr, ln: ln})
+	return withNoDelay(ln), nil
+}
+
+// withNoDelay returns ln as is for the Go default of TCP_NODELAY on every
+// connection, or wrapped to turn it off under -tcp-nodelay=false. The
+// plain listener stays in openListeners, where restarts need its socket.
+func withNoDelay(ln net.Listener) net.Listener {
+	if *tcpNoDelayFlag {
+		return ln
+	}
+	return nagleListener{ln}
+}
+
+// nagleListener clears TCP_NODELAY on accepted connections so the kernel
+// coalesces small writes.
+type nagleListener struct {
+	net.Listener
+}
+
+func (l nagleListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetNoDelay(false)
+	}
+	return conn, nil
+}
+
+// handshakeTimeoutListener gives every accepted connection a read deadline
+// so a client that never finishes the TLS handshake is dropped. net/http
+// replaces the deadline once it starts reading the first request (HTTP/1)
+// or the connection preface (HTTP/2), so it only bounds the handshake.
+type handshakeTimeoutListener struct {
+	net.Listener
+	timeout time.Duration
+}
+
+func (l handshakeTimeoutListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	conn.SetReadDeadline(time.Now().Add(l.timeout))
+	return conn, nil
+}
+
+// tlsProtocols returns the protocols the TLS server offers, or nil for the
+// net/http default of HTTP/1.1 and HTTP/2.
+func tlsProtocols() *http.Protocols {
+	if !*noHTTP2Flag && !*http2OnlyFlag {
+		return nil
+	}
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(!*http2OnlyFlag)
+	protocols.SetHTTP2(!*noHTTP2Flag)
+	return protocols
+}
+
+// bindAddr puts -bind in front of an address without a host of its own,
+// such as :80.
+func bindAddr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host != "" || *bindFlag == "" {
+		return addr
+	}
+	return net.JoinHostPort(*bindFlag, port)
+}
+
+// serveInBackground runs server on ln in its own goroutine and logs why it
+// stopped, unless that was the shutdown.
+func serveInBackground(name string, server *http.Server, ln net.Listener) {
+	go func() {
+		err := server.Serve(ln)
+		if err != nil && err != http.ErrServerClosed {
+			log.Printf("[ERROR] %s server stopped: %v", name, err)
+		}
+	}()
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+var (
+	listingMaxEntriesFlag = flag.Int("listing-max-entries", 0, "(optional) -listing-max-entries Entries shown per directory listing page, further pages via ?page=N, 0 shows everything")
+	emptyDir204Flag       = flag.Bool("empty-dir-204", false, "(optional) -empty-dir-204 Answers requests for empty directories with 204 No Content instead of an empty listing")
+	listingStreamFlag     = flag.Bool("listing-stream", false, "(optional) -listing-stream Streams directory listings as the directory is read instead of reading and sorting it whole first")
+	listingCSSFlag        = flag.String("listing-css", "", "(optional) -listing-css URL of a stylesheet linked from directory listings, whose rows carry a class per file type: folder, image, audio, video, archive, document, code, text or file")
+)
+
+// listingChunk is how many entries are read from the directory between
+// flushes of a streamed listing.
+const listingChunk = 256
+
+// listingHandler renders directory listings a page of -listing-max-entries
+// at a time, or whole when that is 0. Unlike http.FileServer it reads the
+// directory in chunks, writing and flushing each one before reading the
+// next, so memory stays flat and entries come in directory order, not
+// sorted. Directories with an index.html and everything else go to handler.
+func listingHandler(handler http.Handler, root http.FileSystem) http.Handler {
+	limit := *listingMaxEntriesFlag
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean("/" + r.URL.Path)
+		if !strings.HasSuffix(r.URL.Path, "/") || fileExists(root, path.Join(name, "index.html")) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		dir, err := root.Open(name)
+		if err != nil {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		defer dir.Close()
+		info, err := dir.Stat()
+		if err != nil || !info.IsDir() {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		page := 1
+		if value := r.URL.Query().Get("page"); limit > 0 && value != "" {
+			page, err = strconv.Atoi(value)
+			if err != nil || page < 1 {
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+		}
+
+		// Skip the earlier pages. Whether another page follows is only
+		// known after reading one entry past this one.
+		for skip := (page - 1) * limit; skip > 0; {
+			entries, err := dir.Readdir(min(skip, 1024))
+			skip -= len(entries)
+			if err != nil {
+				break
+			}
+		}
+		entries, err := dir.Readdir(listingChunkSize(limit, 0))
+		if err != nil && err != io.EOF {
+			http.Error(w, "Error reading directory", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, "<!doctype html>\n<meta name=\"viewport\" content=\"width=device-width\">\n")
+		if *listingCSSFlag != "" {
+			fmt.Fprintf(w, "<link rel=\"stylesheet\" href=\"%s\">\n", html.EscapeString(*listingCSSFlag))
+		}
+		fmt.Fprintf(w, "<pre>\n")
+		listed := 0
+		for {
+			for _, entry := range entries {
+				entryName := entry.Name()
+				if entry.IsDir() {
+					entryName += "/"
+				}
+				link := url.URL{Path: entryName}
+				fmt.Fprintf(w, "<a class=\"%s\" href=\"%s\">%s</a>\n", entryType(entry), html.EscapeString(link.String()), html.EscapeString(entryName))
+			}
+			listed += len(entries)
+			flushResponse(w)
+			if err != nil || (limit > 0 && listed == limit) {
+				break
+			}
+			entries, err = dir.Readdir(listingChunkSize(limit, listed))
+		}
+		if err != nil && err != io.EOF {
+			// The status is already sent, so a failed read can only cut
+			// the listing short.
+			log.Printf("[ERROR] Reading directory %s: %v", name, err)
+		}
+		fmt.Fprintf(w, "</pre>\n")
+		if page > 1 {
+			fmt.Fprintf(w, "<a href=\"?page=%d\">previous</a>\n", page-1)
+		}
+		if limit > 0 && listed == limit && err == nil {
+			if next, _ := dir.Readdir(1); len(next) > 0 {
+				fmt.Fprintf(w, "<p>Listing truncated at %d entries. <a href=\"?page=%d\">next</a></p>\n", limit, page+1)
+			}
+		}
+	})
+}
+
+// listingChunkSize returns how many entries to read next when listed are
+// already written and at most limit are shown, 0 meaning no limit.
+func listingChunkSize(limit, listed int) int {
+	if limit == 0 {
+		return listingChunk
+	}
+	return min(listingChunk, limit-listed)
+}
+
+// entryTypes classifies files by extension for the class of their listing
+// row. Anything not listed is a plain "file".
+var entryTypes = map[string]string{
+	".png": "image", ".jpg": "image", ".jpeg": "image", ".gif": "image", ".webp": "image", ".svg": "image", ".avif": "image", ".ico": "image", ".bmp": "image",
+	".mp3": "audio", ".ogg": "audio", ".flac": "audio", ".wav": "audio", ".m4a": "audio", ".opus": "audio",
+	".mp4": "video", ".webm": "video", ".mkv": "video", ".mov": "video", ".avi": "video",
+	".zip": "archive", ".tar": "archive", ".gz": "archive", ".tgz": "archive", ".bz2": "archive", ".xz": "archive", ".zst": "archive", ".7z": "archive", ".rar": "archive",
+	".pdf": "document", ".doc": "document", ".docx": "document", ".odt": "document", ".xls": "document", ".xlsx": "document", ".ods": "document", ".ppt": "document", ".pptx": "document", ".epub": "document",
+	".go": "code", ".js": "code", ".ts": "code", ".py": "code", ".c": "code", ".h": "code", ".rs": "code", ".java": "code", ".sh": "code", ".css": "code", ".html": "code", ".json": "code", ".xml": "code", ".yaml": "code", ".yml": "code",
+	".txt": "text", ".md": "text", ".csv": "text", ".log": "text",
+}
+
+func entryType(entry fs.FileInfo) string {
+	if entry.IsDir() {
+		return "folder"
+	}
+	if kind, ok := entryTypes[strings.ToLower(path.Ext(entry.Name()))]; ok {
+		return kind
+	}
+	return "file"
+}
+
+// emptyDirHandler answers 204 for directories without a single entry and
+// hands everything else, including missing directories, to handler.
+func emptyDirHandler(handler http.Handler, root http.FileSystem) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/") || !isEmptyDir(root, path.Clean("/"+r.URL.Path)) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func isEmptyDir(root http.FileSystem, name string) bool {
+	dir, err := root.Open(name)
+	if err != nil {
+		return false
+	}
+	defer dir.Close()
+	info, err := dir.Stat()
+	if err != nil || !info.IsDir() {
+		return false
+	}
+	entries, err := dir.Readdir(1)
+	return len(entries) == 0 && err == io.EOF
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestEmptyDir204(t *testing.T) {
+	*emptyDir204Flag = true
+	t.Cleanup(func() { *emptyDir204Flag = false })
+	site := newTestSite(t, map[string]string{
+		"empty/":        "",
+		"full/file.txt": "content",
+		"file.txt":      "content",
+	})
+
+	tests := []struct {
+		target string
+		status int
+		body   string
+	}{
+		{"/empty/", http.StatusNoContent, ""},
+		{"/full/", http.StatusOK, "file.txt"},
+		{"/missing/", http.StatusNotFound, ""},
+		{"/file.txt", http.StatusOK, "content"},
+	}
+	for _, tt := range tests {
+		w := site.get(http.MethodGet, tt.target, nil)
+		if w.Code != tt.status {
+			t.Errorf("GET %s: status %d, want %d", tt.target, w.Code, tt.status)
+		}
+		if tt.status == http.StatusNoContent && w.Body.Len() != 0 {
+			t.Errorf("GET %s: body %q, want none", tt.target, w.Body.String())
+		}
+		if !strings.Contains(w.Body.String(), tt.body) {
+			t.Errorf("GET %s: body %q, want it to contain %q", tt.target, w.Body.String(), tt.body)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"compress/gzip"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	logCompressFlag   = flag.Bool("log-compress", false, "(optional) -log-compress Gzips rotated copies of the log file, e.g. access.log.1 to access.log.1.gz, after each SIGHUP")
+	maxLogBackupsFlag = flag.Int("max-log-backups", 0, "(optional) -max-log-backups Rotated log files to keep after each SIGHUP, oldest are deleted first, 0 keeps all")
+	// archiveMu keeps two quick SIGHUPs from compressing the same file twice.
+	archiveMu sync.Mutex
+)
+
+// archiveRotatedLogs gzips and prunes the rotated siblings of the log file at
+// path. It runs in its own goroutine after a reopen, so the active file is
+// already a fresh one and never matches.
+func archiveRotatedLogs(path string) {
+	archiveMu.Lock()
+	defer archiveMu.Unlock()
+
+	if *logCompressFlag {
+		rotated, err := rotatedLogs(path)
+		if err != nil {
+			print("[ERROR] Listing rotated logs: " + err.Error())
+			return
+		}
+		for _, name := range rotated {
+			if strings.HasSuffix(name, ".gz") {
+				continue
+			}
+			err = compressLog(name)
+			if err != nil {
+				print("[ERROR] Compressing " + name + ": " + err.Error())
+			}
+		}
+	}
+
+	if *maxLogBackupsFlag > 0 {
+		err := pruneRotatedLogs(path, *maxLogBackupsFlag)
+		if err != nil {
+			print("[ERROR] Pruning rotated logs: " + err.Error())
+		}
+	}
+}
+
+// rotatedLogs lists the files next to path whose name extends it with a . or
+// - suffix, the forms logrotate renames to, newest first.
+func rotatedLogs(path string) ([]string, error) {
+	dir, base := filepath.Split(path)
+	entries, err := os.ReadDir(filepath.Clean(dir))
+	if err != nil {
+		return nil, err
+	}
+	type rotatedLog struct {
+		name    string
+		modTime time.Time
+	}
+	var found []rotatedLog
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || len(name) <= len(base)+1 || !strings.HasPrefix(name, base) {
+			continue
+		}
+		if sep := name[len(base)]; sep != '.' && sep != '-' {
+			continue
+		}
+		// .tmp is a compression still in flight.
+		if strings.HasSuffix(name, ".tmp") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		found = append(found, rotatedLog{name: filepath.Join(dir, name), modTime: info.ModTime()})
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].modTime.After(found[j].modTime) })
+	names := make([]string, len(found))
+	for i, f := range found {
+		names[i] = f.name
+	}
+	return names, nil
+}
+
+// compressLog writes name.gz and removes name once it is complete. The
+// original modification time is kept so pruning still sorts by age. If
+// name.gz already exists, e.g. because logrotate reused the number, the
+// modification time is added to the new name instead of overwriting it.
+func compressLog(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	target := name + ".gz"
+	if _, err := os.Stat(target); err == nil {
+		target = name + "." + info.ModTime().Format("20060102-150405") + ".gz"
+	}
+	tmp := target + ".tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_EXCL|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if err == nil {
+		err = zw.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	err = os.Chtimes(tmp, info.ModTime(), info.ModTime())
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	err = os.Rename(tmp, target)
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Remove(name)
+}
+
+// pruneRotatedLogs deletes all but the keep newest rotated logs.
+func pruneRotatedLogs(path string, keep int) error {
+	rotated, err := rotatedLogs(path)
+	if err != nil {
+		return err
+	}
+	if len(rotated) <= keep {
+		return nil
+	}
+	for _, name := range rotated[keep:] {
+		err = os.Remove(name)
+		if err != nil {
+			return err
+		}
+		print("[INFO] Removed old log file " + name)
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	logFileModeFlag      = flag.String("log-file-mode", "0644", "(optional) -log-file-mode Octal permissions for created log files")
+	logDirModeFlag       = flag.String("log-dir-mode", "0755", "(optional) -log-dir-mode Octal permissions for created log directories")
+	logBufferSizeFlag    = flag.Int("log-buffer-size", 0, "(optional) -log-buffer-size Bytes of access log held in memory before writing to -l, 0 writes every entry straight away")
+	logFlushIntervalFlag = flag.Duration("log-flush-interval", time.Second, "(optional) -log-flush-interval How often a -log-buffer-size buffer is written out even when not full")
+	logFileMode          os.FileMode
+	logDirMode           os.FileMode
+	// accessLog is the -l log file, opened once at startup and reopened on SIGHUP.
+	accessLog *logFile
+	// hangupFiles are every log file reopened on SIGHUP.
+	hangupFiles []*logFile
+)
+
+// parseFileMode parses an octal permission string such as 0640.
+func parseFileMode(value string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, errors.New("[ERROR] Invalid octal file mode: " + value)
+	}
+	return os.FileMode(mode), nil
+}
+
+// logFile is an append-only file that can be closed and reopened at the same
+// path, which is what logrotate expects after it renames the file. Writes
+// go through buf when it is set. A named pipe at path is written without
+// blocking, see writeFifo.
+type logFile struct {
+	mu      sync.Mutex
+	path    string
+	perm    os.FileMode
+	dirPerm os.FileMode
+	file    *os.File
+	buf     *bufio.Writer
+	fifo    bool
+	// lastOpen is when a reader was last looked for on the pipe, and
+	// dropped counts the entries lost since one was last attached.
+	lastOpen time.Time
+	dropped  int
+}
+
+func openLogFile(path string, perm, dirPerm os.FileMode) (*logFile, error) {
+	f := &logFile{path: path, perm: perm, dirPerm: dirPerm}
+	err := f.open()
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *logFile) open() error {
+	// create file dir if not exists
+	err := os.MkdirAll(filepath.Dir(f.path), f.dirPerm)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(f.path)
+	f.fifo = err == nil && info.Mode()&os.ModeNamedPipe != 0
+	if f.fifo {
+		f.lastOpen = time.Now()
+		f.file, err = openFifo(f.path)
+		return err
+	}
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, f.perm)
+	if err != nil {
+		return err
+	}
+	f.file = file
+	return nil
+}
+
+// buffer holds up to size bytes in memory and writes them out when full and
+// every interval, trading the last interval of entries on a crash for far
+// fewer write calls.
+func (f *logFile) buffer(size int, interval time.Duration) {
+	f.mu.Lock()
+	f.buf = bufio.NewWriterSize(rawLogWriter{f}, size)
+	f.mu.Unlock()
+	go func() {
+		for range time.Tick(interval) {
+			err := f.Flush()
+			if err != nil {
+				print("[ERROR] Flushing log file: " + err.Error())
+			}
+		}
+	}()
+}
+
+// Flush writes out anything still buffered.
+func (f *logFile) Flush() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.buf == nil {
+		return nil
+	}
+	return f.buf.Flush()
+}
+
+// Write writes p as a single append. The mutex keeps concurrent entries from
+// interleaving and from racing a reopen.
+func (f *logFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.buf != nil {
+		return f.buf.Write(p)
+	}
+	return f.writeRaw(p)
+}
+
+// rawLogWriter is what a logFile's buffer writes out to. The logFile's
+// mutex is already held whenever the buffer writes.
+type rawLogWriter struct {
+	f *logFile
+}
+
+func (w rawLogWriter) Write(p []byte) (int, error) {
+	return w.f.writeRaw(p)
+}
+
+func (f *logFile) writeRaw(p []byte) (int, error) {
+	if f.fifo {
+		return f.writeFifo(p), nil
+	}
+	return f.file.Write(p)
+}
+
+// writeFifo writes p to the named pipe without ever holding up a request.
+// Entries are dropped while no reader has the pipe open or the pipe is
+// full. After the reader goes away the pipe is reopened at most once a
+// second, so a restarted reader picks up where the last one left off.
+func (f *logFile) writeFifo(p []byte) int {
+	if f.file == nil {
+		if time.Since(f.lastOpen) < time.Second {
+			f.dropped++
+			return len(p)
+		}
+		f.lastOpen = time.Now()
+		file, err := openFifo(f.path)
+		if err != nil || file == nil {
+			f.dropped++
+			return len(p)
+		}
+		f.file = file
+		if f.dropped > 0 {
+			print("[WARN] Dropped " + strconv.Itoa(f.dropped) + " log entries while no reader was attached to " + f.path)
+			f.dropped = 0
+		}
+	}
+	_, err := writeNonBlocking(f.file, p)
+	if err == errPipeFull {
+		f.dropped++
+	} else if err != nil {
+		// The reader closed its end.
+		f.file.Close()
+		f.file = nil
+		f.dropped++
+	}
+	return len(p)
+}
+
+// Reopen closes the current handle and opens the path again, creating a fresh
+// file if the old one was renamed away.
+func (f *logFile) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.buf != nil {
+		f.buf.Flush()
+	}
+	if f.file != nil {
+		f.file.Close()
+	}
+	return f.open()
+}
+
+func (f *logFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.buf != nil {
+		f.buf.Flush()
+	}
+	if f.file == nil {
+		return nil
+	}
+	return f.file.Close()
+}
+
+// reopenOnHangup reopens every log file each time the process gets SIGHUP,
+// then archives the rotated copies of the access logs in the background if
+// asked to.
+func reopenOnHangup() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+	for range sig {
+		for _, f := range hangupFiles {
+			err := f.Reopen()
+			if err != nil {
+				print("[ERROR] Reopening log file: " + err.Error())
+				continue
+			}
+			print("[INFO] Reopened log file " + f.path)
+			if (*logCompressFlag || *maxLogBackupsFlag > 0) && isAccessLogFile(f) {
+				go archiveRotatedLogs(f.path)
+			}
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// formatLogfmt renders requestLog as key=value pairs with the same keys as
+// the JSON output. Values that are empty or contain spaces, quotes, = or
+// control characters are quoted.
+func formatLogfmt(requestLog RequestLog) []byte {
+	var b strings.Builder
+	for i, attr := range requestLogAttrs(requestLog) {
+		a := attr.(slog.Attr)
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		if a.Value.Kind() == slog.KindGroup {
+			// Groups such as Headers are flattened to Headers.Accept=...
+			for j, member := range a.Value.Group() {
+				if j > 0 {
+					b.WriteByte(' ')
+				}
+				b.WriteString(a.Key + "." + member.Key)
+				b.WriteByte('=')
+				b.WriteString(logfmtValue(member.Value.String()))
+			}
+			continue
+		}
+		b.WriteString(a.Key)
+		b.WriteByte('=')
+		b.WriteString(logfmtValue(a.Value.String()))
+	}
+	b.WriteByte('\n')
+	return []byte(b.String())
+}
+
+func logfmtValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " =\"\\") || strings.ContainsFunc(value, func(r rune) bool { return r < ' ' || r == 0x7f }) {
+		return strconv.Quote(value)
+	}
+	return value
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+var (
+	logSinkFlags stringList
+	logSinkSpecs []logSinkSpec
+	// logSinks are every destination access log entries are written to:
+	// -log or -l, plus -bot-log and -error-log-file.
+	logSinks []*logSink
+)
+
+func init() {
+	flag.Var(&logSinkFlags, "log", "(optional) -log 'target:format' Writes access log entries to target, stderr, stdout or a file, as tab, json, logfmt or gelf. Repeatable, each with its own format, e.g. -log stderr:tab -log /var/log/access.json:json. Replaces -l and -format")
+}
+
+// logSinkSpec is one -log value.
+type logSinkSpec struct {
+	target string
+	format string
+}
+
+// logSink is one destination for access log entries with its own format:
+// tab, json, logfmt, gelf, slog or template.
+type logSink struct {
+	format string
+	out    io.Writer
+	// file is out when it is a log file, so it is flushed at shutdown and
+	// its rotated copies archived.
+	file *logFile
+	// tab and slog write the formats that go through a logger.
+	tab  *log.Logger
+	slog *slog.Logger
+	// accepts picks the entries written to the sink, all of them when nil.
+	accepts func(RequestLog) bool
+}
+
+func newLogSink(format string, out io.Writer) *logSink {
+	sink := &logSink{format: format, out: out, tab: log.New(out, "", log.Flags())}
+	sink.file, _ = out.(*logFile)
+	if format == "slog" {
+		sink.slog = slog.New(slog.NewJSONHandler(out, slogOptions()))
+	}
+	return sink
+}
+
+func (s *logSink) write(requestLog RequestLog) error {
+	switch s.format {
+	case "tab":
+		s.tab.Print(formatTab(requestLog))
+		return nil
+	case "slog":
+		s.slog.Info("request", requestLogAttrs(requestLog)...)
+		return nil
+	}
+	line, err := formatLogEntry(s.format, requestLog)
+	if err != nil {
+		return err
+	}
+	_, err = s.out.Write(line)
+	return err
+}
+
+// formatTab renders requestLog as a tab format line, without the timestamp
+// the logger puts in front.
+func formatTab(requestLog RequestLog) string {
+	return fmt.Sprintf("%s %s %s %s %s %s %s %d %d %d %s", requestLog.RemoteAddr, requestLog.URL, requestLog.UserAgent, requestLog.Referer, requestLog.Method, requestLog.RequestURI, requestLog.Protocol, requestLog.Status, requestLog.Written, requestLog.DateTime, requestLog.Host)
+}
+
+// formatLogEntry renders requestLog as one json, logfmt, gelf or
+// -log-template line.
+func formatLogEntry(format string, requestLog RequestLog) ([]byte, error) {
+	switch format {
+	case "logfmt":
+		return formatLogfmt(requestLog), nil
+	case "template":
+		return formatLogTemplate(requestLog)
+	}
+	var entry any = requestLog
+	if format == "gelf" {
+		entry = gelfMessage(requestLog)
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
+
+func parseLogSinks(values []string) ([]logSinkSpec, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	if *logFileFlag != "" || *logFormatFlag != "tab" || *slogFlag || *logTemplateFlag != "" {
+		return nil, errors.New("[ERROR] -log sets the access log destinations and formats, it cannot be combined with -l, -format, -j, -slog or -log-template")
+	}
+	specs := make([]logSinkSpec, 0, len(values))
+	for _, value := range values {
+		i := strings.LastIndex(value, ":")
+		if i <= 0 {
+			return nil, errors.New("[ERROR] Invalid -log, expected target:format: " + value)
+		}
+		spec := logSinkSpec{target: value[:i], format: value[i+1:]}
+		switch spec.format {
+		case "tab", "json", "logfmt", "gelf":
+		default:
+			return nil, errors.New("[ERROR] Unknown -log format " + spec.format + ", expected tab, json, logfmt or gelf")
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// setupLogSinks builds logSinks once the log files are open. Bot entries
+// go to -bot-log instead of the access log, and entries with a 4xx or 5xx
+// status are copied to -error-log-file in the -l format.
+func setupLogSinks() error {
+	if len(logSinkSpecs) == 0 {
+		logSinks = append(logSinks, accessLogSink())
+	}
+	for _, spec := range logSinkSpecs {
+		sink, err := openLogSink(spec)
+		if err != nil {
+			return err
+		}
+		logSinks = append(logSinks, sink)
+	}
+	if botLog != nil {
+		for _, sink := range logSinks {
+			sink.accepts = func(requestLog RequestLog) bool { return !requestLog.IsBot }
+		}
+		bots := newLogSink("json", botLog)
+		bots.accepts = func(requestLog RequestLog) bool { return requestLog.IsBot }
+		logSinks = append(logSinks, bots)
+	}
+	if errorAccessLog != nil {
+		failed := newLogSink(accessLogFormat(), errorAccessLog)
+		failed.accepts = func(requestLog RequestLog) bool { return requestLog.Status >= 400 }
+		logSinks = append(logSinks, failed)
+	}
+	return nil
+}
+
+// accessLogFormat is the format -l and -error-log-file are written in.
+func accessLogFormat() string {
+	if *slogFlag {
+		return "slog"
+	}
+	if logTemplate != nil {
+		return "template"
+	}
+	return *logFormatFlag
+}
+
+// accessLogSink writes to -l, or stderr without it, and to -gelf-udp
+// instead when set. The standard logger already writes tab entries to -l
+// as well as stderr, and setupSlog points slog's default logger at -l.
+func accessLogSink() *logSink {
+	var out io.Writer = os.Stderr
+	if accessLog != nil {
+		out = accessLog
+	}
+	format := accessLogFormat()
+	if format == "gelf" && gelfConn != nil {
+		out = gelfUDPWriter{}
+	}
+	sink := newLogSink(format, out)
+	sink.tab = log.Default()
+	if format == "slog" {
+		sink.slog = slog.Default()
+	}
+	return sink
+}
+
+// openLogSink opens the file a -log value names and registers it for
+// reopening on SIGHUP.
+func openLogSink(spec logSinkSpec) (*logSink, error) {
+	if spec.format == "gelf" && gelfHost == "" {
+		err := setupGelf()
+		if err != nil {
+			return nil, err
+		}
+	}
+	switch spec.target {
+	case "stderr":
+		return newLogSink(spec.format, os.Stderr), nil
+	case "stdout":
+		return newLogSink(spec.format, os.Stdout), nil
+	}
+	f, err := openLogFile(spec.target, logFileMode, logDirMode)
+	if err != nil {
+		return nil, err
+	}
+	if *logBufferSizeFlag > 0 {
+		f.buffer(*logBufferSizeFlag, *logFlushIntervalFlag)
+	}
+	hangupFiles = append(hangupFiles, f)
+	return newLogSink(spec.format, f), nil
+}
+
+// flushLogSinks writes out what the log files still buffer.
+func flushLogSinks() {
+	for _, sink := range logSinks {
+		if sink.file == nil {
+			continue
+		}
+		err := sink.file.Flush()
+		if err != nil {
+			log.Print(err)
+		}
+	}
+}
+
+// isAccessLogFile reports whether f holds access log entries, whose
+// rotated copies -log-compress and -max-log-backups look after.
+func isAccessLogFile(f *logFile) bool {
+	for _, sink := range logSinks {
+		if sink.file == f {
+			return true
+		}
+	}
+	return false
+}
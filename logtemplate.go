@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"strconv"
+	"text/template"
+	"time"
+)
+
+var (
+	logTemplateFlag = flag.String("log-template", "", "(optional) -log-template text/template executed with each RequestLog to produce its log line, e.g. '{{.RemoteAddr}} {{.Method}} {{.URL}} {{.Status}}'. Replaces -format")
+	logTemplate     *template.Template
+)
+
+// logTemplateFuncs help reproduce common formats in -log-template.
+var logTemplateFuncs = template.FuncMap{
+	// time renders a DateTime with a time.Format layout in the -utc zone.
+	"time": func(value int64, layout string) string {
+		return logTime(time.Unix(0, value*int64(dateTimeUnit))).Format(layout)
+	},
+	"quote": strconv.Quote,
+	// dash stands in for empty values the way Apache logs do.
+	"dash": func(value string) string {
+		if value == "" {
+			return "-"
+		}
+		return value
+	},
+}
+
+func parseLogTemplate(value string) (*template.Template, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if *slogFlag || *logFormatFlag != "tab" {
+		return nil, errors.New("[ERROR] -log-template replaces -format, -j and -slog, pick one")
+	}
+	tmpl, err := template.New("log").Funcs(logTemplateFuncs).Parse(value)
+	if err != nil {
+		return nil, errors.New("[ERROR] Invalid -log-template: " + err.Error())
+	}
+	// Fields that do not exist only show up on execution, so try it once.
+	err = tmpl.Execute(&bytes.Buffer{}, RequestLog{})
+	if err != nil {
+		return nil, errors.New("[ERROR] Invalid -log-template: " + err.Error())
+	}
+	return tmpl, nil
+}
+
+// formatLogTemplate executes -log-template for requestLog, ending the line
+// with a newline if the template does not.
+func formatLogTemplate(requestLog RequestLog) ([]byte, error) {
+	var line bytes.Buffer
+	err := logTemplate.Execute(&line, requestLog)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasSuffix(line.Bytes(), []byte("\n")) {
+		line.WriteByte('\n')
+	}
+	return line.Bytes(), nil
+}
@@ -1,17 +1,21 @@
 package main
 
 import (
-	"encoding/json"
+	"crypto/tls"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
-	"path/filepath"
-	"sync"
+	"strconv"
+	"strings"
 	"time"
+
+	"golang.org/x/time/rate"
 )
 
 var (
@@ -19,52 +23,324 @@ var (
 	listenPortFlag     = flag.String("p", "", "-p Port to listen on. Kinda optional, will use 80 if not provided")
 	logFileFlag        = flag.String("l", "", "(optional) -l Log file to write access logs")
 	logJSON            = flag.Bool("j", false, "(optional) -j Saves log results as JSON. Requires logfile to be provided")
+	logFormatFlag      = flag.String("format", "tab", "(optional) -format Log format: tab, json, gelf or logfmt. -j is shorthand for -format json")
+	utcFlag            = flag.Bool("utc", false, "(optional) -utc Renders log timestamps in UTC instead of local time")
+	slogFlag           = flag.Bool("slog", false, "(optional) -slog Emits access logs as JSON through log/slog, to the log file if provided or stderr")
 	redirectHttpsFlag  = flag.Bool("r", false, "(optional) -r Redirect using port 80 to port 443")
+	redirectAddrFlag   = flag.String("redirect-addr", ":80", "(optional) -redirect-addr Address the -r redirect server listens on")
+	bindFlag           = flag.String("bind", "", "(optional) -bind IP address the site, -r and -dual listeners bind to, all interfaces when unset")
+	dualFlag           = flag.Bool("dual", false, "(optional) -dual Also serves the full site over plain HTTP on port 80 alongside TLS")
 	serveDirectoryFlag = flag.String("d", "", "(optional) -d Path to directory to serve")
 	certChainPathFlag  = flag.String("c", "", "(optional) -c Path to cert chain")
 	certPrivKeyFlag    = flag.String("k", "", "(optional) -k Path to cert private key")
+	rootRedirectFlag   = flag.String("root-redirect", "", "(optional) -root-redirect URL to redirect requests for / to")
+	rootRedirectCode   = flag.Int("root-redirect-code", http.StatusFound, "(optional) -root-redirect-code Status code used by -root-redirect")
 	isTLS              = false
-	logFileMutex       = sync.Mutex{}
 )
 
 func main() {
 
-	checkFlags()
+	err := checkFlags()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *genURLFlag != "" {
+		fmt.Println(signedURL(*genURLFlag, time.Now().Add(*genURLExpiryFlag)))
+		return
+	}
+
+	if *utcFlag {
+		log.SetFlags(log.Flags() | log.LUTC)
+	}
+
+	err = loadInheritedListeners()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *pidFileFlag != "" {
+		err = writePidFile()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *logFileFlag != "" {
+		err = setupLogFile()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *slogFlag {
+		setupSlog()
+	}
+
+	if *slowThresholdFlag > 0 {
+		err = setupSlowLog()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *auditLogFlag != "" {
+		err = setupAuditLog()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *botLogFlag != "" {
+		err = setupBotLog()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *errorLogFileFlag != "" {
+		err = setupErrorAccessLog()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *logFormatFlag == "gelf" {
+		err = setupGelf()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	err = setupLogSinks()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = setupErrorLog()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(hangupFiles) > 0 {
+		go reopenOnHangup()
+	}
+
+	if *otelFlag {
+		err = setupOtel()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	err = setupMarkdownTemplate()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = setupMaintenance()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *robotsFlag != "" {
+		err = setupRobots()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var root http.FileSystem = http.Dir(*serveDirectoryFlag)
+	if *archiveFlag != "" {
+		root, err = openArchive(*archiveFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *delayFlag > 0 || *delayJitterFlag > 0 {
+		print("[WARN] Delaying every request. This is for testing only")
+	}
+	if *errorRateFlag > 0 {
+		print("[WARN] Failing a fraction of requests on purpose. This is for testing only")
+	}
+	site := siteHandler(root)
+	if *fileFlag != "" {
+		site = singleFileHandler()
+	}
+	if *benchmarkFlag > 0 {
+		print("[WARN] Serving a fixed in-memory response to every request and logging none of them. This is for testing only")
+		site = benchmarkHandler()
+	}
+	// Served directly rather than through a ServeMux, so odd paths are
+	// logged and checked before cleanPathHandler redirects them.
+	handler := Chain(site, siteMiddlewares()...)
+
+	var servers []*http.Server
+	if isTLS && *redirectHttpsFlag {
+		redirect := []Middleware{logHandler, availabilityHandler, requireHostHandler}
+		if len(allowedHosts) > 0 {
+			redirect = append(redirect, hostHandler)
+		}
+		redirectAddr := bindAddr(*redirectAddrFlag)
+		redirectListener, err := listen(redirectAddr)
+		if err != nil {
+			log.Fatal("[ERROR] Cannot listen for -r redirects on " + redirectAddr + ": " + err.Error())
+		}
+		redirectServer := &http.Server{Handler: Chain(http.HandlerFunc(redirectHttpsHandler), redirect...), ErrorLog: serverErrorLog}
+		servers = append(servers, redirectServer)
+		serveInBackground("Redirect", redirectServer, redirectListener)
+	}
+
+	if isTLS && *dualFlag {
+		plainAddr := bindAddr(":80")
+		plainListener, err := listen(plainAddr)
+		if err != nil {
+			log.Fatal("[ERROR] Cannot listen for -dual on " + plainAddr + ": " + err.Error())
+		}
+		plainServer := &http.Server{Handler: handler, ErrorLog: serverErrorLog, DisableGeneralOptionsHandler: true}
+		servers = append(servers, plainServer)
+		serveInBackground("Plain HTTP", plainServer, plainListener)
+	}
+
+	if *adminAddrFlag != "" {
+		adminListener, err := listen(*adminAddrFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		adminServer := newAdminServer()
+		adminServer.ErrorLog = serverErrorLog
+		servers = append(servers, adminServer)
+		serveInBackground("Admin", adminServer, adminListener)
+	}
+
+	ln, err := listen(bindAddr(":" + *listenPortFlag))
+	if err != nil {
+		log.Fatal(err)
+	}
+	// OPTIONS * is left to optionsHandler so it is answered and logged like
+	// any other request.
+	server := &http.Server{Handler: handler, ErrorLog: serverErrorLog, DisableGeneralOptionsHandler: true}
+	servers = append(servers, server)
+
+	if *selfSignedFlag {
+		print("[WARN] Serving a self-signed certificate. This is for local development only")
+		cert, err := selfSignedCertificate()
+		if err != nil {
+			log.Fatal(err)
+		}
+		server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+
+	if *summaryIntervalFlag > 0 {
+		go logSummaries(*summaryIntervalFlag)
+	}
 
-	http.Handle("/", logHandler(http.FileServer(http.Dir(*serveDirectoryFlag))))
+	done := make(chan struct{})
+	go shutdownOnSignal(servers, done)
+	go restartOnSignal()
+	serverState.Store(stateReady)
+	notifyParent()
 
 	if isTLS {
-		if *redirectHttpsFlag {
-			go http.ListenAndServe(":80", logHandler(http.HandlerFunc(redirectHttpsHandler)))
+		server.Protocols = tlsProtocols()
+		if *tlsHandshakeTimeoutFlag > 0 {
+			ln = handshakeTimeoutListener{Listener: ln, timeout: *tlsHandshakeTimeoutFlag}
 		}
-		log.Fatal(http.ListenAndServeTLS(":"+*listenPortFlag, *certChainPathFlag, *certPrivKeyFlag, nil))
+		err = server.ServeTLS(ln, *certChainPathFlag, *certPrivKeyFlag)
 	} else {
-		log.Fatal(http.ListenAndServe(":"+*listenPortFlag, nil))
+		err = server.Serve(ln)
+	}
+	if err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	<-done
+	flushLogSinks()
+	shutdownOtel()
+	// After a restart the pid file already belongs to the new process.
+	if *pidFileFlag != "" && !restarting.Load() {
+		removePidFile()
 	}
 }
 
 func logHandler(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		startTime := time.Now()
+		stats.inFlight.Add(1)
+		defer stats.inFlight.Add(-1)
 
 		o := &responseObserver{ResponseWriter: w}
 
+		r, notes := withRequestNotes(r)
+		defer func() {
+			if o.download {
+				stats.downloads.Add(-1)
+			}
+		}()
+
 		handler.ServeHTTP(o, r)
 
 		duration := time.Now().Sub(startTime)
 
+		// A failed write or a cancelled context means the client went away
+		// mid response, so the status and byte count alone would overstate
+		// what was delivered.
+		clientClosed := o.writeErr != nil || r.Context().Err() != nil
+		status := o.status
+		if clientClosed {
+			status = statusClientClosed
+		}
+		if notes.dropped {
+			status = statusDropped
+		}
+
+		// Errors and redirects may have opened a file without sending it.
+		servedPath := notes.servedPath
+		if status >= 300 && status != http.StatusNotModified {
+			servedPath = ""
+		}
+
+		logURL := *r.URL
+		if notes.rewrittenPath != "" {
+			logURL.Path, logURL.RawPath = notes.rewrittenPath, ""
+		}
+
 		requestLog := RequestLog{
-			RemoteAddr: r.RemoteAddr,
-			URL:        r.URL.String(),
-			UserAgent:  r.UserAgent(),
-			Referer:    r.Referer(),
-			Method:     r.Method,
-			RequestURI: r.RequestURI,
-			Protocol:   r.Proto,
-			Status:     o.status,
-			Written:    o.written,
-			DateTime:   time.Now().UnixNano() / 1e6,
-			TimeTaken:  duration.Nanoseconds() / 1e6,
+			RemoteAddr:       r.RemoteAddr,
+			URL:              logQuery(logURL.String()),
+			Host:             r.Host,
+			IsBot:            isBot(r.UserAgent()),
+			UserAgent:        r.UserAgent(),
+			Referer:          r.Referer(),
+			Method:           r.Method,
+			RequestURI:       logQuery(r.RequestURI),
+			Protocol:         r.Proto,
+			Status:           status,
+			Written:          o.written,
+			ContentLength:    o.contentLength,
+			DateTime:         dateTime(time.Now()),
+			DateTimeUnit:     dateTimeUnitName(),
+			TimeTaken:        duration.Nanoseconds() / 1e6,
+			ClientClosed:     clientClosed,
+			Upstream:         notes.upstream,
+			ServedPath:       servedPath,
+			Headers:          logHeaders(r),
+			TransferEncoding: o.transferEncoding(r),
+			Labels:           logLabels,
+			GRPCStatus:       o.grpcStatus(),
+		}
+
+		if *otelFlag {
+			annotateSpan(r, &requestLog)
+		}
+
+		stats.record(requestLog)
+
+		if auditLog != nil {
+			err := auditLog.audit(r, requestLog)
+			if err != nil {
+				log.Fatal(err)
+			}
 		}
 
 		err := writeLog(requestLog)
@@ -72,86 +348,149 @@ func logHandler(handler http.Handler) http.Handler {
 			log.Fatal(err)
 		}
 
-		if *logFileFlag == "" {
-			log.Printf("%s %s %s %s %s %s %s %s %s %s", requestLog.RemoteAddr, requestLog.URL, requestLog.UserAgent, requestLog.Referer, requestLog.Method, requestLog.RequestURI, requestLog.Protocol, requestLog.Status, requestLog.Written, requestLog.DateTime)
+		if *slowThresholdFlag > 0 && duration > *slowThresholdFlag {
+			logSlowRequest(requestLog, duration)
 		}
 
+		if notes.dropped {
+			// Aborting the handler closes the connection, or resets the
+			// stream over HTTP/2, without writing a response.
+			panic(http.ErrAbortHandler)
+		}
 	})
 }
 
 func redirectHttpsHandler(w http.ResponseWriter, req *http.Request) {
-	target := "https://" + req.Host + req.URL.Path
+	// The request came in on the plain HTTP port, so the port in Host, if
+	// any, is swapped for the one TLS is served on.
+	host := req.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	} else {
+		// An IPv6 literal without a port, e.g. [::1].
+		host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	}
+	if *listenPortFlag != "443" {
+		host = net.JoinHostPort(host, *listenPortFlag)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	target := "https://" + host + req.URL.Path
 	if len(req.URL.RawQuery) > 0 {
 		target += "?" + req.URL.RawQuery
 	}
 	http.Redirect(w, req, target, http.StatusTemporaryRedirect)
 }
 
-func writeLog(requestLog RequestLog) error {
-	if *logFileFlag != "" {
+// logTime returns t in the zone log timestamps are rendered in.
+func logTime(t time.Time) time.Time {
+	if *utcFlag {
+		return t.UTC()
+	}
+	return t
+}
 
-		// check if log file exists
-		_, err := os.Stat(*logFileFlag)
-		logFileExists := false
+// siteHandler serves the files in root, with the handlers that read
+// straight from it layered on top.
+func siteHandler(root http.FileSystem) http.Handler {
+	if *maxOpenFilesFlag > 0 {
+		root = countingFS{root}
+	}
+	var handler http.Handler = fileServerHandler(root)
+	if *listingMaxEntriesFlag > 0 || *listingStreamFlag || *listingCSSFlag != "" {
+		handler = listingHandler(handler, root)
+	}
+	if *emptyDir204Flag {
+		handler = emptyDirHandler(handler, root)
+	}
+	if upstreamURL != nil {
+		handler = upstreamHandler(handler, root)
+	}
+	if *noDirRedirectFlag {
+		handler = noDirRedirectHandler(handler, root)
+	}
+	if *xaccelFlag != "" {
+		handler = xaccelHandler(handler, root)
+	}
+	if *renderExtFlag != "" {
+		handler = renderHandler(handler, root)
+	}
+	if *renderMarkdownFlag {
+		handler = markdownHandler(handler, root)
+	}
+	if *i18nFlag {
+		handler = i18nHandler(handler, root)
+	}
+	if *sitemapFlag {
+		handler = sitemapHandler(handler, root)
+	}
+	if *robotsFlag != "" {
+		handler = robotsHandler(handler, root)
+	}
+	if *canonicalPathFlag {
+		handler = canonicalPathHandler(handler, root)
+	}
+	if *maxOpenFilesFlag > 0 {
+		handler = openFilesHandler(handler)
+	}
+	return handler
+}
 
-		// create file dir if not exists
-		if err != nil {
-			dir := filepath.Dir(*logFileFlag)
-			err := os.MkdirAll(dir, os.ModePerm)
-			if err != nil {
-				return err
-			}
-		} else {
-			logFileExists = true
+// rootRedirectHandler redirects requests for exactly / to -root-redirect and
+// passes every other path through to handler.
+func rootRedirectHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			handler.ServeHTTP(w, r)
+			return
 		}
+		target := *rootRedirectFlag
+		// http.Redirect resolves a relative target against the path
+		// -base-path left, so resolve it against the one requested.
+		if u, err := url.Parse(target); err == nil && u.Scheme == "" && u.Host == "" && !strings.HasPrefix(target, "/") {
+			target = *basePathFlag + "/" + target
+		}
+		http.Redirect(w, r, target, *rootRedirectCode)
+	})
+}
 
-		if *logJSON {
-			logFileMutex.Lock()
-			defer logFileMutex.Unlock()
-			err := writeLogFileJson(logFileExists, requestLog)
-			if err != nil {
-				return err
-			}
-		} else {
-			err := writeLogTab(requestLog)
-			if err != nil {
-				return err
-			}
+func writeLog(requestLog RequestLog) error {
+	var errs []error
+	for _, sink := range logSinks {
+		if sink.accepts == nil || sink.accepts(requestLog) {
+			errs = append(errs, sink.write(requestLog))
 		}
 	}
-	return nil
+	return errors.Join(errs...)
 }
 
-func writeLogFileJson(logFileExists bool, logEntry RequestLog) error {
-	logJSON, err := json.Marshal(logEntry)
+// setupLogFile opens the -l log file and registers it for reopening on SIGHUP. In
+// tab mode the standard logger writes to it alongside stderr.
+func setupLogFile() error {
+	f, err := openLogFile(*logFileFlag, logFileMode, logDirMode)
 	if err != nil {
 		return err
 	}
-	f, err := os.OpenFile(*logFileFlag, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return err
+	accessLog = f
+	if *logBufferSizeFlag > 0 {
+		accessLog.buffer(*logBufferSizeFlag, *logFlushIntervalFlag)
 	}
-	defer f.Close()
-	if _, err = f.WriteString(string(logJSON) + "\n"); err != nil {
-		return err
-	}
-	return nil
-}
-
-func writeLogTab(requestLog RequestLog) error {
-	f, err := os.OpenFile(*logFileFlag, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
-	if err != nil {
-		return err
+	if *logFormatFlag == "tab" && !*slogFlag && *logTemplateFlag == "" {
+		log.SetOutput(io.MultiWriter(os.Stderr, accessLog))
 	}
-	log.SetOutput(io.MultiWriter(os.Stderr, f))
-	log.Printf("%s %s %s %s %s %s %s", requestLog.RemoteAddr, requestLog.URL, requestLog.UserAgent, requestLog.Referer, requestLog.Method, requestLog.RequestURI, requestLog.Protocol)
-	defer f.Close()
+	hangupFiles = append(hangupFiles, accessLog)
 	return nil
 }
 
 func checkFlags() error {
 
 	flag.Parse()
+	if err := checkDuplicateFlags(os.Args[1:]); err != nil {
+		return err
+	}
+	if *genURLFlag != "" {
+		return checkSignFlags()
+	}
 	if *listenPortFlag == "" {
 		print("[INFO] No listen port provided, setting listen port to 80")
 		*listenPortFlag = "80"
@@ -171,24 +510,279 @@ func checkFlags() error {
 		}
 	}
 
-	if *listenPortFlag == "443" && (*certChainPathFlag == "" || *certPrivKeyFlag == "") {
+	if *selfSignedFlag && (*certChainPathFlag != "" || *certPrivKeyFlag != "") {
+		return errors.New("[ERROR] -self-signed cannot be combined with -c or -k")
+	}
+
+	if *listenPortFlag == "443" && !*selfSignedFlag && (*certChainPathFlag == "" || *certPrivKeyFlag == "") {
 		return errors.New("[ERROR] Provided port 443 but no certificate!")
 	}
 
-	if *certChainPathFlag != "" && *certPrivKeyFlag != "" {
+	if (*certChainPathFlag != "" && *certPrivKeyFlag != "") || *selfSignedFlag {
 		isTLS = true
 	}
 
-	if *logJSON && *logFileFlag == "" {
+	if *dualFlag && !isTLS {
+		return errors.New("[ERROR] -dual requires a certificate")
+	}
+
+	if *noHTTP2Flag && *http2OnlyFlag {
+		return errors.New("[ERROR] -no-http2 and -http2-only are mutually exclusive")
+	}
+
+	if (*noHTTP2Flag || *http2OnlyFlag) && !isTLS {
+		return errors.New("[ERROR] -no-http2 and -http2-only require a certificate")
+	}
+
+	if *dualFlag && *redirectHttpsFlag {
+		if _, port, _ := net.SplitHostPort(*redirectAddrFlag); port == "80" {
+			return errors.New("[ERROR] -dual and -r both listen on port 80, pick one or move -r with -redirect-addr")
+		}
+	}
+
+	if _, _, err := net.SplitHostPort(*redirectAddrFlag); err != nil {
+		return errors.New("[ERROR] Invalid -redirect-addr " + *redirectAddrFlag + ": " + err.Error())
+	}
+
+	if *bindFlag != "" && net.ParseIP(*bindFlag) == nil {
+		return errors.New("[ERROR] -bind must be an IP address: " + *bindFlag)
+	}
+
+	if *logJSON {
+		if *logFormatFlag != "tab" && *logFormatFlag != "json" {
+			return errors.New("[ERROR] -j conflicts with -format " + *logFormatFlag)
+		}
+		*logFormatFlag = "json"
+	}
+
+	switch *logFormatFlag {
+	case "tab", "json", "gelf", "logfmt":
+	default:
+		return errors.New("[ERROR] Unknown log format " + *logFormatFlag)
+	}
+
+	if *logFormatFlag == "json" && *logFileFlag == "" {
 		return errors.New("[ERROR] Specified logging as JSON but did not provide log file path")
 	}
 
+	if *logFormatFlag == "gelf" && *logFileFlag == "" && *gelfUDPFlag == "" {
+		return errors.New("[ERROR] Specified logging as GELF but did not provide log file path or -gelf-udp")
+	}
+
+	if *slogFlag && *logJSON {
+		return errors.New("[ERROR] -slog and -j are mutually exclusive")
+	}
+
+	if *slogFlag && *logFormatFlag != "tab" {
+		return errors.New("[ERROR] -slog conflicts with -format " + *logFormatFlag)
+	}
+
+	if err := checkIgnoredFlags(); err != nil {
+		return err
+	}
+
+	if err := checkStatusPath(); err != nil {
+		return err
+	}
+
+	var err error
+	logFileMode, err = parseFileMode(*logFileModeFlag)
+	if err != nil {
+		return err
+	}
+	logDirMode, err = parseFileMode(*logDirModeFlag)
+	if err != nil {
+		return err
+	}
+
+	logTemplate, err = parseLogTemplate(*logTemplateFlag)
+	if err != nil {
+		return err
+	}
+
+	logSinkSpecs, err = parseLogSinks(logSinkFlags)
+	if err != nil {
+		return err
+	}
+
+	minTLSVersion, err = parseMinTLSVersion(*minTLSVersionFlag)
+	if err != nil {
+		return err
+	}
+	if minTLSVersion != 0 && !isTLS {
+		return errors.New("[ERROR] -min-tls-version requires a certificate")
+	}
+
+	noCompressTypes = parseNoCompressTypes(*noCompressTypesFlag)
+	varyHeaders = parseVaryHeaders(*varyFlag)
+	forceDownloadTypes = parseExtensionList(*forceDownloadFlag)
+	inlineTypes = parseExtensionList(*inlineFlag)
+	redactedParams = parseRedactedParams(*logRedactFlag)
+	loggedHeaders, err = parseLoggedHeaders(*logHeadersFlag)
+	if err != nil {
+		return err
+	}
+	logLabels, err = parseLogLabels(logLabelFlags)
+	if err != nil {
+		return err
+	}
+	allowedHosts = parseAllowedHosts(hostFlags)
+	sitemapHost, err = parseSitemapHost()
+	if err != nil {
+		return err
+	}
+
+	dateTimeUnit, err = parseTimePrecision(*timePrecisionFlag)
+	if err != nil {
+		return err
+	}
+
+	noRangesPatterns, err = parseNoRangesPatterns(noRangesFlags)
+	if err != nil {
+		return err
+	}
+
+	immutablePatterns, err = parseImmutablePatterns(immutablePatternFlags)
+	if err != nil {
+		return err
+	}
+
+	httpsOnlyPatterns, err = parseHTTPSOnlyPatterns(httpsOnlyPathFlags)
+	if err != nil {
+		return err
+	}
+	if len(httpsOnlyPatterns) > 0 && !*dualFlag {
+		return errors.New("[ERROR] -https-only-path requires -dual, without it nothing is served over plain HTTP")
+	}
+	if *httpsOnlyRejectFlag && len(httpsOnlyPatterns) == 0 {
+		return errors.New("[ERROR] -https-only-reject requires -https-only-path")
+	}
+
+	botPatterns, err = parseBotPatterns(botPatternFlags)
+	if err != nil {
+		return err
+	}
+
+	blockUAPatterns, err = parseBlockUAPatterns(blockUAFlags)
+	if err != nil {
+		return err
+	}
+	if *dropFlag && len(blockUAPatterns) == 0 {
+		return errors.New("[ERROR] -drop requires -block-ua")
+	}
+
+	*cookieSameSiteFlag, err = parseCookieSameSite(*cookieSameSiteFlag)
+	if err != nil {
+		return err
+	}
+
+	if *healthSentinelFlag != "" && !*healthDeepFlag {
+		return errors.New("[ERROR] -health-sentinel requires -health-deep")
+	}
+
+	upstreamURL, err = parseUpstream(*upstreamFlag)
+	if err != nil {
+		return err
+	}
+
+	vhosts, err = parseVhosts(vhostFlags)
+	if err != nil {
+		return err
+	}
+
+	if *logBufferSizeFlag < 0 {
+		return errors.New("[ERROR] -log-buffer-size must not be negative")
+	}
+	if *logBufferSizeFlag > 0 && *logFlushIntervalFlag <= 0 {
+		return errors.New("[ERROR] -log-flush-interval must be positive")
+	}
+
+	if *maxLogBackupsFlag < 0 {
+		return errors.New("[ERROR] -max-log-backups must not be negative")
+	}
+	if (*logCompressFlag || *maxLogBackupsFlag > 0) && *logFileFlag == "" {
+		return errors.New("[ERROR] -log-compress and -max-log-backups require a log file")
+	}
+
+	*basePathFlag, err = parseBasePath(*basePathFlag)
+	if err != nil {
+		return err
+	}
+
+	if *listingMaxEntriesFlag < 0 {
+		return errors.New("[ERROR] -listing-max-entries must not be negative")
+	}
+
+	if *summaryIntervalFlag < 0 {
+		return errors.New("[ERROR] -summary-interval must not be negative")
+	}
+
+	if *tcpKeepAliveFlag < 0 {
+		return errors.New("[ERROR] -tcp-keepalive must not be negative")
+	}
+
+	err = checkSignFlags()
+	if err != nil {
+		return err
+	}
+
+	err = checkFaultFlags()
+	if err != nil {
+		return err
+	}
+
+	if *maxConnsPerIPFlag < 0 {
+		return errors.New("[ERROR] -max-conns-per-ip must not be negative")
+	}
+
+	if *maxOpenFilesFlag < 0 || *maxOpenFilesWaitFlag < 0 {
+		return errors.New("[ERROR] -max-open-files and -max-open-files-wait must not be negative")
+	}
+
+	if *bandwidthLimitFlag < 0 {
+		return errors.New("[ERROR] Bandwidth limit must not be negative")
+	}
+	if *bandwidthLimitFlag > 0 {
+		globalBandwidth = newByteLimiter(*bandwidthLimitFlag)
+	}
+
+	if *perConnBandwidthFlag < 0 {
+		return errors.New("[ERROR] Per connection bandwidth must not be negative")
+	}
+
+	rules, err := parseRewriteRules(rewriteFlags)
+	if err != nil {
+		return err
+	}
+	rewriteRules = rules
+
+	if *xaccelFlag != "" && !strings.HasPrefix(*xaccelFlag, "/") {
+		return errors.New("[ERROR] -xaccel must be an absolute location such as /protected/")
+	}
+	if *xaccelFlag != "" && (*archiveFlag != "" || len(vhostFlags) > 0) {
+		return errors.New("[ERROR] -xaccel serves from one directory and cannot be combined with -archive or -vhost")
+	}
+
+	if *archiveFlag != "" && *serveDirectoryFlag != "" {
+		return errors.New("[ERROR] -archive and -d are mutually exclusive")
+	}
+
+	err = checkFileFlags()
+	if err != nil {
+		return err
+	}
+
+	if *rootRedirectFlag != "" && (*rootRedirectCode < 300 || *rootRedirectCode > 399) {
+		return errors.New("[ERROR] Root redirect code must be a 3xx status")
+	}
+
 	return nil
 }
 
 type RequestLog struct {
 	RemoteAddr string
 	URL        string
+	Host       string
 	UserAgent  string
 	Referer    string
 	Method     string
@@ -196,8 +790,54 @@ type RequestLog struct {
 	Protocol   string
 	Status     int
 	Written    int64
-	DateTime   int64
-	TimeTaken  int64
+	// ContentLength is the Content-Length header that was sent, if any. It
+	// differs from Written for HEAD requests and cut off responses.
+	ContentLength int64
+	DateTime      int64
+	// DateTimeUnit is the -time-precision of DateTime: s, ms, us or ns.
+	DateTimeUnit string
+	TimeTaken    int64
+	TraceId      string `json:",omitempty"`
+	IsBot        bool
+	// ClientClosed is set when the client disconnected before the response
+	// was complete. Status is then statusClientClosed.
+	ClientClosed bool
+	// Upstream is set when the response came from -upstream because the
+	// file was missing locally.
+	Upstream bool
+	// ServedPath is the absolute path of the file the response was read
+	// from, with -log-served-path.
+	ServedPath string `json:",omitempty"`
+	// Headers are the request headers picked by -log-headers.
+	Headers map[string]string `json:",omitempty"`
+	// TransferEncoding is how the response body was delimited, see
+	// responseObserver.transferEncoding.
+	TransferEncoding string
+	// Labels are the -log-label pairs, the same on every entry.
+	Labels map[string]string `json:",omitempty"`
+	// GRPCStatus is the grpc-status a gRPC response ended with, empty for
+	// everything else.
+	GRPCStatus string `json:",omitempty"`
+}
+
+// statusClientClosed is nginx's 499, logged in place of the status that was
+// sent when the client disconnects mid response.
+const statusClientClosed = 499
+
+// statusDropped is nginx's 444, logged for -drop requests whose connection
+// is closed without a response.
+const statusDropped = 444
+
+// stringList collects the values of a flag that may be repeated.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
 }
 
 // https://gist.github.com/blixt/01d6bdf8aa8ae57d5c72c1907b6db670
@@ -206,14 +846,34 @@ type responseObserver struct {
 	status      int
 	written     int64
 	wroteHeader bool
+	throttles   []*rate.Limiter
+	writeErr    error
+	// contentLength is the Content-Length the response advertised.
+	contentLength int64
+	// lengthDeclared and trailers record whether the header had a
+	// Content-Length or announced trailers, and flushed whether the
+	// handler flushed, which together decide how net/http frames the body.
+	lengthDeclared bool
+	trailers       bool
+	flushed        bool
+	// download is set once a 200 or 206 has been sent, and counted in
+	// stats.downloads until logHandler is done with the request.
+	download bool
 }
 
 func (o *responseObserver) Write(p []byte) (n int, err error) {
 	if !o.wroteHeader {
 		o.WriteHeader(http.StatusOK)
 	}
-	n, err = o.ResponseWriter.Write(p)
+	if len(o.throttles) > 0 {
+		n, err = throttledWrite(o.ResponseWriter, p, o.throttles...)
+	} else {
+		n, err = o.ResponseWriter.Write(p)
+	}
 	o.written += int64(n)
+	if err != nil && o.writeErr == nil {
+		o.writeErr = err
+	}
 	return
 }
 
@@ -224,4 +884,72 @@ func (o *responseObserver) WriteHeader(code int) {
 	}
 	o.wroteHeader = true
 	o.status = code
+	var err error
+	o.contentLength, err = strconv.ParseInt(o.Header().Get("Content-Length"), 10, 64)
+	o.lengthDeclared = err == nil
+	o.trailers = o.Header().Get("Trailer") != ""
+	if code == http.StatusOK || code == http.StatusPartialContent {
+		o.download = true
+		stats.downloads.Add(1)
+	}
+	o.throttles = responseThrottles(o.Header())
+}
+
+// Flush sends any buffered response data to the client, so handlers that
+// stream, like directory listings, are not held up by the observer.
+func (o *responseObserver) Flush() {
+	if !o.wroteHeader {
+		o.WriteHeader(http.StatusOK)
+	}
+	o.flushed = true
+	flushResponse(o.ResponseWriter)
+}
+
+// chunkingThreshold is how much of a body net/http buffers before it gives
+// up on computing a Content-Length and starts chunking.
+const chunkingThreshold = 2048
+
+// grpcStatus reads grpc-status once the handler has returned, when the
+// header map holds the trailers it sent. A trailers-only response, which
+// gRPC uses for immediate errors, carries it in the header instead.
+func (o *responseObserver) grpcStatus() string {
+	h := o.Header()
+	if status := h.Get(http.TrailerPrefix + "Grpc-Status"); status != "" {
+		return status
+	}
+	return h.Get("Grpc-Status")
+}
+
+// transferEncoding works out how the body of a finished response was
+// delimited: "length" with a Content-Length, "chunked" for HTTP/1.1
+// chunking, "stream" for HTTP/2 and later without a length and "close" for
+// HTTP/1.0 bodies ended by closing the connection. Responses without a body
+// get "". A handler that never flushed and wrote less than
+// chunkingThreshold gets a Content-Length computed by net/http.
+func (o *responseObserver) transferEncoding(r *http.Request) string {
+	status := o.status
+	if !o.wroteHeader {
+		status = http.StatusOK
+	}
+	if r.Method == http.MethodHead || status < 200 || status == http.StatusNoContent || status == http.StatusNotModified {
+		return ""
+	}
+	if o.lengthDeclared || (!o.flushed && !o.trailers && o.written < chunkingThreshold) {
+		return "length"
+	}
+	switch {
+	case r.ProtoMajor >= 2:
+		return "stream"
+	case r.ProtoAtLeast(1, 1):
+		return "chunked"
+	}
+	return "close"
+}
+
+// flushResponse flushes w if it supports it. Each observer calls it from
+// its own Flush so a flush reaches the connection through every layer.
+func flushResponse(w http.ResponseWriter) {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
 }
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// testSite serves a temporary directory through the middleware chain the
+// current flags enable and keeps the entries logHandler writes.
+type testSite struct {
+	http.Handler
+	dir string
+	log entryBuffer
+}
+
+// newTestSite writes files, keyed by slash separated path, into a fresh
+// directory and serves it. A key ending in / makes an empty directory.
+func newTestSite(t *testing.T, files map[string]string) *testSite {
+	t.Helper()
+	site := &testSite{dir: t.TempDir()}
+	for name, content := range files {
+		p := filepath.Join(site.dir, filepath.FromSlash(name))
+		if strings.HasSuffix(name, "/") {
+			if err := os.MkdirAll(p, 0755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sinks, state := logSinks, serverState.Load()
+	logSinks = []*logSink{newLogSink("json", &site.log)}
+	serverState.Store(stateReady)
+	t.Cleanup(func() {
+		logSinks = sinks
+		serverState.Store(state)
+	})
+	site.Handler = Chain(siteHandler(http.Dir(site.dir)), siteMiddlewares()...)
+	return site
+}
+
+// get serves a request for target with the given header and returns the
+// recorded response.
+func (s *testSite) get(method, target string, header map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	for name, value := range header {
+		r.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+// lastEntry returns the most recent access log entry.
+func (s *testSite) lastEntry(t *testing.T) RequestLog {
+	t.Helper()
+	entries := s.log.entries(t)
+	if len(entries) == 0 {
+		t.Fatal("no access log entry written")
+	}
+	return entries[len(entries)-1]
+}
+
+// entryBuffer collects JSON access log lines, which a server goroutine
+// may write while the test reads them.
+type entryBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *entryBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *entryBuffer) entries(t *testing.T) []RequestLog {
+	t.Helper()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var entries []RequestLog
+	decoder := json.NewDecoder(bytes.NewReader(b.buf.Bytes()))
+	for decoder.More() {
+		var entry RequestLog
+		if err := decoder.Decode(&entry); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestRedirectHttpsHandler(t *testing.T) {
+	port := *listenPortFlag
+	t.Cleanup(func() { *listenPortFlag = port })
+
+	tests := []struct {
+		port string
+		host string
+		want string
+	}{
+		{"443", "example.com", "https://example.com/a?b=c"},
+		{"443", "example.com:80", "https://example.com/a?b=c"},
+		{"443", "[::1]", "https://[::1]/a?b=c"},
+		{"443", "[::1]:80", "https://[::1]/a?b=c"},
+		{"8443", "example.com:8080", "https://example.com:8443/a?b=c"},
+		{"8443", "[::1]", "https://[::1]:8443/a?b=c"},
+		{"8443", "[::1]:8080", "https://[::1]:8443/a?b=c"},
+	}
+	for _, tt := range tests {
+		*listenPortFlag = tt.port
+		r := httptest.NewRequest(http.MethodGet, "/a?b=c", nil)
+		r.Host = tt.host
+		w := httptest.NewRecorder()
+		redirectHttpsHandler(w, r)
+		if got := w.Header().Get("Location"); got != tt.want {
+			t.Errorf("-p %s, Host %s: Location %q, want %q", tt.port, tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestClientClosedMidBody(t *testing.T) {
+	site := newTestSite(t, map[string]string{"big.bin": strings.Repeat("x", 32<<20)})
+	server := httptest.NewServer(site)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/big.bin", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(resp.Body, make([]byte, 64<<10)); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	resp.Body.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(site.log.entries(t)) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("no access log entry written after the client went away")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	entry := site.lastEntry(t)
+	if !entry.ClientClosed || entry.Status != statusClientClosed {
+		t.Errorf("logged ClientClosed %v and status %d, want true and %d", entry.ClientClosed, entry.Status, statusClientClosed)
+	}
+	if entry.Written >= 32<<20 {
+		t.Errorf("logged %d bytes written, want fewer than the whole file", entry.Written)
+	}
+}
+
+func TestHeadLogsContentLength(t *testing.T) {
+	site := newTestSite(t, map[string]string{"file.txt": strings.Repeat("x", 1234)})
+
+	w := site.get(http.MethodHead, "/file.txt", nil)
+	if w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Fatalf("HEAD: status %d with %d body bytes, want 200 with none", w.Code, w.Body.Len())
+	}
+	entry := site.lastEntry(t)
+	if entry.Written != 0 || entry.ContentLength != 1234 {
+		t.Errorf("HEAD: logged Written %d and ContentLength %d, want 0 and 1234", entry.Written, entry.ContentLength)
+	}
+
+	site.get(http.MethodGet, "/file.txt", nil)
+	entry = site.lastEntry(t)
+	if entry.Written != 1234 || entry.ContentLength != 1234 {
+		t.Errorf("GET: logged Written %d and ContentLength %d, want 1234 and 1234", entry.Written, entry.ContentLength)
+	}
+}
+
+func TestReopenOnHangup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+	f, err := openLogFile(path, 0644, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	saved := hangupFiles
+	hangupFiles = []*logFile{f}
+	t.Cleanup(func() { hangupFiles = saved })
+
+	// Until reopenOnHangup has registered, SIGHUP would end the test
+	// binary, so catch it here as well.
+	caught := make(chan os.Signal, 1)
+	signal.Notify(caught, syscall.SIGHUP)
+	t.Cleanup(func() { signal.Stop(caught) })
+	go reopenOnHangup()
+
+	f.Write([]byte("before\n"))
+	rotated := filepath.Join(dir, "access.log.1")
+	if err := os.Rename(path, rotated); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		syscall.Kill(os.Getpid(), syscall.SIGHUP)
+		time.Sleep(10 * time.Millisecond)
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("log file was not reopened after SIGHUP")
+		}
+	}
+	f.Write([]byte("after\n"))
+
+	for name, want := range map[string]string{rotated: "before\n", path: "after\n"} {
+		got, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s holds %q, want %q", filepath.Base(name), got, want)
+		}
+	}
+}
+
+// TestExpectContinue checks that a client waiting on Expect: 100-continue
+// gets the final status without 100 Continue, since no handler reads the
+// body.
+func TestExpectContinue(t *testing.T) {
+	tests := []struct {
+		maxBodySize int64
+		target      string
+		want        int
+	}{
+		{10, "/a.txt", http.StatusRequestEntityTooLarge},
+		{0, "/missing.txt", http.StatusNotFound},
+	}
+	t.Cleanup(func() { *maxBodySizeFlag = 0 })
+	for _, tt := range tests {
+		*maxBodySizeFlag = tt.maxBodySize
+		site := newTestSite(t, map[string]string{"a.txt": "a"})
+		server := httptest.NewServer(site)
+		conn, err := net.Dial("tcp", server.Listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		io.WriteString(conn, "PUT "+tt.target+" HTTP/1.1\r\nHost: localhost\r\nContent-Length: 1000\r\nExpect: 100-continue\r\n\r\n")
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.want {
+			t.Errorf("PUT %s: got %d, want %d before any body", tt.target, resp.StatusCode, tt.want)
+		}
+		conn.Close()
+		server.Close()
+	}
+}
+
+func TestXAccelRedirectEscaped(t *testing.T) {
+	*xaccelFlag = "/protected/"
+	t.Cleanup(func() { *xaccelFlag = "" })
+	site := newTestSite(t, map[string]string{"a b%?é.txt": "a"})
+	w := site.get("GET", "/a%20b%25%3F%C3%A9.txt", nil)
+	if got, want := w.Header().Get("X-Accel-Redirect"), "/protected/a%20b%25%3F%C3%A9.txt"; got != want {
+		t.Errorf("X-Accel-Redirect %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	maintenanceFileFlag = flag.String("maintenance-file", "", "(optional) -maintenance-file Path that, while it exists, puts the server in maintenance and answers every request with 503")
+	maintenancePageFlag = flag.String("maintenance-page", "", "(optional) -maintenance-page HTML file sent as the body of maintenance 503s instead of plain text")
+	maintenancePage     []byte
+)
+
+// maintenanceCheckInterval bounds how stale the cached existence check of
+// -maintenance-file can be.
+const maintenanceCheckInterval = time.Second
+
+// maintenanceState caches whether -maintenance-file exists so a busy server
+// stats it at most once per interval.
+type maintenanceState struct {
+	mu      sync.Mutex
+	checked time.Time
+	active  bool
+}
+
+func (m *maintenanceState) isActive() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if time.Since(m.checked) >= maintenanceCheckInterval {
+		_, err := os.Stat(*maintenanceFileFlag)
+		m.active = err == nil
+		m.checked = time.Now()
+	}
+	return m.active
+}
+
+// setupMaintenance reads -maintenance-page once, so the page is still
+// served if its file is moved away during the maintenance.
+func setupMaintenance() error {
+	if *maintenancePageFlag == "" {
+		return nil
+	}
+	page, err := os.ReadFile(*maintenancePageFlag)
+	if err != nil {
+		return err
+	}
+	maintenancePage = page
+	return nil
+}
+
+// maintenanceHandler answers 503 with a Retry-After header while
+// -maintenance-file exists. The admin server's health checks are not
+// affected.
+func maintenanceHandler(handler http.Handler) http.Handler {
+	state := &maintenanceState{}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !state.isActive() {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(*retryAfterFlag))
+		if maintenancePage == nil {
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write(maintenancePage)
+	})
+}
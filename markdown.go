@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+var (
+	renderMarkdownFlag   = flag.Bool("render-markdown", false, "(optional) -render-markdown Serves .md files, and README.md for directories without an index.html, as HTML. Add ?raw=1 for the source")
+	markdownTemplateFlag = flag.String("markdown-template", "", "(optional) -markdown-template html/template file rendered markdown is wrapped in, with .Title and .Content")
+	markdownTemplate     = template.Must(template.New("markdown").Parse(defaultMarkdownTemplate))
+	markdown             = goldmark.New(goldmark.WithExtensions(extension.GFM))
+)
+
+const defaultMarkdownTemplate = `<!doctype html>
+<html><head><meta charset="utf-8"><meta name="viewport" content="width=device-width"><title>{{.Title}}</title></head>
+<body>
+{{.Content}}
+</body></html>
+`
+
+// markdownPage is what -markdown-template is executed with.
+type markdownPage struct {
+	Title   string
+	Content template.HTML
+}
+
+// setupMarkdownTemplate parses -markdown-template in place of the default.
+func setupMarkdownTemplate() error {
+	if *markdownTemplateFlag == "" {
+		return nil
+	}
+	tmpl, err := template.ParseFiles(*markdownTemplateFlag)
+	if err != nil {
+		return err
+	}
+	markdownTemplate = tmpl
+	return nil
+}
+
+// markdownHandler renders .md files from root as HTML and hands every other
+// request, and any with ?raw=1, to handler. Where page.md and page.html sit
+// side by side, requests for either get whichever was modified last, so a
+// pre-rendered page is used until its source changes.
+func markdownHandler(handler http.Handler, root http.FileSystem) http.Handler {
+	base := servedPathBase(root)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("raw") == "1" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		name := path.Clean("/" + r.URL.Path)
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/"):
+			if fileExists(root, path.Join(name, "index.html")) || !fileExists(root, path.Join(name, "README.md")) {
+				handler.ServeHTTP(w, r)
+				return
+			}
+			name = path.Join(name, "README.md")
+		case path.Ext(name) == ".html":
+			source := strings.TrimSuffix(name, ".html") + ".md"
+			sourceInfo, ok := statFile(root, source)
+			if !ok {
+				handler.ServeHTTP(w, r)
+				return
+			}
+			if info, ok := statFile(root, name); ok && !sourceInfo.ModTime().After(info.ModTime()) {
+				handler.ServeHTTP(w, r)
+				return
+			}
+			name = source
+		case path.Ext(name) == ".md":
+			rendered := strings.TrimSuffix(name, ".md") + ".html"
+			if info, ok := statFile(root, rendered); ok {
+				if sourceInfo, ok := statFile(root, name); ok && info.ModTime().After(sourceInfo.ModTime()) {
+					serveFile(w, r, root, base, rendered)
+					return
+				}
+			}
+		default:
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		f, err := root.Open(name)
+		if err != nil {
+			if os.IsNotExist(err) {
+				http.NotFound(w, r)
+				return
+			}
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			renderError(w, r, err)
+			return
+		}
+		noteServedPath(r, base, name)
+		page, err := renderedMarkdown.get(path.Join(base, name), info, func() ([]byte, error) { return renderMarkdown(f, name) })
+		if err != nil {
+			renderError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		serveGenerated(w, r, info.ModTime(), page)
+	})
+}
+
+// renderMarkdown converts the markdown in f and wraps it in the template.
+func renderMarkdown(f io.Reader, name string) ([]byte, error) {
+	src, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	var content bytes.Buffer
+	err = markdown.Convert(src, &content)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	err = markdownTemplate.Execute(&out, markdownPage{Title: path.Base(name), Content: template.HTML(content.String())})
+	if err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// renderedMarkdown holds each rendered page until its source file changes.
+var renderedMarkdown = &pageCache{pages: map[string]cachedPage{}}
+
+// pageCache maps a source file to the page rendered from it, keyed by the
+// file's absolute path so -vhost sites sharing names do not collide.
+type pageCache struct {
+	mu    sync.Mutex
+	pages map[string]cachedPage
+}
+
+type cachedPage struct {
+	modTime time.Time
+	size    int64
+	page    []byte
+}
+
+// get returns the cached page for key while info still matches the file it
+// was rendered from, and otherwise renders and stores it again.
+func (c *pageCache) get(key string, info os.FileInfo, render func() ([]byte, error)) ([]byte, error) {
+	c.mu.Lock()
+	cached, ok := c.pages[key]
+	c.mu.Unlock()
+	if ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.page, nil
+	}
+	page, err := render()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.pages[key] = cachedPage{modTime: info.ModTime(), size: info.Size(), page: page}
+	c.mu.Unlock()
+	return page, nil
+}
+
+// serveFile sends the regular file name from root as it is.
+func serveFile(w http.ResponseWriter, r *http.Request, root http.FileSystem, base, name string) {
+	f, err := root.Open(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	noteServedPath(r, base, name)
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
+
+// statFile returns the FileInfo of name in root if it is a regular file.
+func statFile(root http.FileSystem, name string) (os.FileInfo, bool) {
+	f, err := root.Open(name)
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		return nil, false
+	}
+	return info, true
+}
+
+// fileExists reports whether name can be opened as a regular file in root.
+func fileExists(root http.FileSystem, name string) bool {
+	_, ok := statFile(root, name)
+	return ok
+}
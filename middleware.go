@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+)
+
+// Middleware wraps a handler in one layer of behaviour.
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps handler in middlewares, the first of which ends up outermost
+// and sees each request first.
+func Chain(handler http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// siteMiddlewares returns the layers the flags enable around the served
+// files, outermost first. Tracing sees the request before anything else and
+// -mask-403 sits outside logHandler so the real status is still logged.
+// Everything inside availabilityHandler only runs once the server is ready,
+// and everything inside cleanPathHandler sees a cleaned path.
+func siteMiddlewares() []Middleware {
+	var middlewares []Middleware
+	add := func(enabled bool, middleware Middleware) {
+		if enabled {
+			middlewares = append(middlewares, middleware)
+		}
+	}
+	add(*otelFlag, otelHandler)
+	add(*mask403Flag, mask403Handler)
+	add(*benchmarkFlag == 0, logHandler)
+	add(true, availabilityHandler)
+	add(*rejectTraversalFlag, traversalHandler)
+	add(true, cleanPathHandler)
+	add(minTLSVersion != 0, minTLSVersionHandler)
+	add(len(httpsOnlyPatterns) > 0, httpsOnlyHandler)
+	add(len(blockUAPatterns) > 0, blockUAHandler)
+	add(*maintenanceFileFlag != "", maintenanceHandler)
+	add(*errorRateFlag > 0, errorRateHandler)
+	add(*delayFlag > 0 || *delayJitterFlag > 0, delayHandler)
+	add(true, requireHostHandler)
+	add(len(allowedHosts) > 0, hostHandler)
+	add(*signKeyFlag != "", signedURLHandler)
+	add(true, optionsHandler)
+	add(*basePathFlag != "", basePathHandler)
+	add(*maxConnsPerIPFlag > 0, maxConnsPerIPHandler)
+	add(*maxPathLenFlag > 0, maxPathLenHandler)
+	add(*maxBodySizeFlag > 0, maxBodySizeHandler)
+	add(len(varyHeaders) > 0, varyHandler)
+	add(*cookieSecureFlag || *cookieSameSiteFlag != "", cookieHandler)
+	add(len(forceDownloadTypes) > 0 || len(inlineTypes) > 0, dispositionHandler)
+	add(len(immutablePatterns) > 0, immutableHandler)
+	add(*checksumTrailerFlag, checksumHandler)
+	add(len(noRangesPatterns) > 0, noRangesHandler)
+	add(*gzipFlag, gzipHandler)
+	add(len(rewriteRules) > 0, rewriteHandler)
+	add(*rootRedirectFlag != "", rootRedirectHandler)
+	add(len(vhosts) > 0, vhostHandler)
+	return middlewares
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"net/http"
+)
+
+// requestNotes carries what handlers deep in the chain learn about a
+// request back out to logHandler, which puts it in the RequestLog.
+type requestNotes struct {
+	// upstream is set when the response was proxied from -upstream.
+	upstream bool
+	// rewrittenPath is the request path after -rewrite, with -base-path in
+	// front. It is set even when a middleware such as http.StripPrefix
+	// copied the URL, so logHandler's own copy never saw the rewrite.
+	rewrittenPath string
+	// servedPath is the file on disk the response was read from.
+	servedPath string
+	// dropped is set when -drop wants the connection closed without a
+	// response, which logHandler does once the request is logged.
+	dropped bool
+}
+
+type requestNotesKey struct{}
+
+// withRequestNotes returns r with empty notes attached to its context.
+func withRequestNotes(r *http.Request) (*http.Request, *requestNotes) {
+	notes := &requestNotes{}
+	return r.WithContext(context.WithValue(r.Context(), requestNotesKey{}, notes)), notes
+}
+
+// notesFrom returns the notes attached to r, or nil when logHandler did
+// not attach any, e.g. in the admin server.
+func notesFrom(r *http.Request) *requestNotes {
+	notes, _ := r.Context().Value(requestNotesKey{}).(*requestNotes)
+	return notes
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+)
+
+// headerObserver is the shared base of the middlewares that adjust a
+// response right before its header goes out: beforeHeader runs once with
+// the final status, may change the header, and returns the status to send.
+// afterHeader, when set, runs once that header is sent. Informational 1xx
+// headers such as 103 Early Hints pass straight through. Middlewares that
+// also change the body embed it and wrap Write.
+type headerObserver struct {
+	http.ResponseWriter
+	beforeHeader func(code int) int
+	afterHeader  func()
+	wroteHeader  bool
+}
+
+func (o *headerObserver) WriteHeader(code int) {
+	if o.wroteHeader {
+		return
+	}
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		o.ResponseWriter.WriteHeader(code)
+		return
+	}
+	o.wroteHeader = true
+	if o.beforeHeader != nil {
+		code = o.beforeHeader(code)
+	}
+	o.ResponseWriter.WriteHeader(code)
+	if o.afterHeader != nil {
+		o.afterHeader()
+	}
+}
+
+func (o *headerObserver) Write(p []byte) (int, error) {
+	if !o.wroteHeader {
+		o.WriteHeader(http.StatusOK)
+	}
+	return o.ResponseWriter.Write(p)
+}
+
+func (o *headerObserver) Flush() {
+	if !o.wroteHeader {
+		o.WriteHeader(http.StatusOK)
+	}
+	flushResponse(o.ResponseWriter)
+}
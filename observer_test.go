@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHeaderObserver(t *testing.T) {
+	rec := httptest.NewRecorder()
+	calls := 0
+	o := &headerObserver{ResponseWriter: rec, beforeHeader: func(code int) int {
+		calls++
+		rec.Header().Set("X-Status", http.StatusText(code))
+		return code
+	}}
+	o.Write([]byte("body"))
+	o.WriteHeader(http.StatusNotFound)
+	if calls != 1 {
+		t.Errorf("beforeHeader ran %d times, want once", calls)
+	}
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Status") != "OK" || rec.Body.String() != "body" {
+		t.Errorf("got %d %q %q, want 200 OK body", rec.Code, rec.Header().Get("X-Status"), rec.Body)
+	}
+}
+
+func TestMask403Observer(t *testing.T) {
+	rec := httptest.NewRecorder()
+	o := newMask403Observer(rec)
+	o.Header().Set("Content-Length", "9")
+	o.WriteHeader(http.StatusForbidden)
+	o.Write([]byte("forbidden"))
+	if rec.Code != http.StatusNotFound || rec.Body.String() != "404 page not found\n" {
+		t.Errorf("got %d %q, want the 404 page", rec.Code, rec.Body)
+	}
+	if rec.Header().Get("Content-Length") != "" {
+		t.Errorf("Content-Length %q of the 403 body was kept", rec.Header().Get("Content-Length"))
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	maxOpenFilesFlag     = flag.Int("max-open-files", 0, "(optional) -max-open-files Requests served from disk at once, each holding a file open. More wait up to -max-open-files-wait, then get 503 with Retry-After. 0 means no limit")
+	maxOpenFilesWaitFlag = flag.Duration("max-open-files-wait", time.Second, "(optional) -max-open-files-wait How long a request waits for one of the -max-open-files slots before it is refused")
+)
+
+// openFilesHandler lets at most -max-open-files requests into the site
+// handler at a time. Each one holds a single file open at a time, save a
+// directory and its index.html briefly, so this keeps the process under
+// its descriptor limit where the alternative is random 500s from
+// "too many open files".
+func openFilesHandler(handler http.Handler) http.Handler {
+	slots := make(chan struct{}, *maxOpenFilesFlag)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case slots <- struct{}{}:
+		default:
+			timer := time.NewTimer(*maxOpenFilesWaitFlag)
+			defer timer.Stop()
+			select {
+			case slots <- struct{}{}:
+			case <-timer.C:
+				log.Printf("[WARN] All %d file slots busy, refused %s %s", *maxOpenFilesFlag, r.Method, r.RequestURI)
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+				return
+			case <-r.Context().Done():
+				return
+			}
+		}
+		defer func() { <-slots }()
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// countingFS keeps stats.openFiles up to date with the files opened
+// through it and not yet closed.
+type countingFS struct {
+	http.FileSystem
+}
+
+// Unwrap returns the file system being counted.
+func (fs countingFS) Unwrap() http.FileSystem {
+	return fs.FileSystem
+}
+
+// rootDir returns the directory root serves from, looking through
+// wrappers such as countingFS, and false when root is not a directory.
+func rootDir(root http.FileSystem) (http.Dir, bool) {
+	for {
+		switch fs := root.(type) {
+		case http.Dir:
+			return fs, true
+		case interface{ Unwrap() http.FileSystem }:
+			root = fs.Unwrap()
+		default:
+			return "", false
+		}
+	}
+}
+
+func (fs countingFS) Open(name string) (http.File, error) {
+	f, err := fs.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	stats.openFiles.Add(1)
+	return &countedFile{File: f}, nil
+}
+
+type countedFile struct {
+	http.File
+	closed atomic.Bool
+}
+
+func (f *countedFile) Close() error {
+	if f.closed.CompareAndSwap(false, true) {
+		stats.openFiles.Add(-1)
+	}
+	return f.File.Close()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	otelFlag       = flag.Bool("otel", false, "(optional) -otel Traces requests with OpenTelemetry, exporting spans over OTLP/HTTP as configured by the OTEL_EXPORTER_OTLP_* environment variables")
+	tracerProvider *sdktrace.TracerProvider
+)
+
+// setupOtel installs a tracer provider that batches spans to the OTLP
+// endpoint and propagates W3C trace context and baggage. The exporter and
+// service name are configured through the standard OTEL_* variables.
+func setupOtel() error {
+	exporter, err := otlptracehttp.New(context.Background())
+	if err != nil {
+		return err
+	}
+	tracerProvider = sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter))
+	otel.SetTracerProvider(tracerProvider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return nil
+}
+
+// otelHandler starts a server span for every request, continuing any trace
+// the client sent in its headers.
+func otelHandler(handler http.Handler) http.Handler {
+	return otelhttp.NewHandler(handler, "goHttpServer", otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
+		return r.Method
+	}))
+}
+
+// annotateSpan records the served path and response size on the request's
+// span and copies its trace ID into requestLog so logs and traces correlate.
+func annotateSpan(r *http.Request, requestLog *RequestLog) {
+	span := trace.SpanFromContext(r.Context())
+	if !span.SpanContext().IsValid() {
+		return
+	}
+	span.SetAttributes(
+		attribute.String("http.route", r.URL.Path),
+		attribute.Int64("http.response.body.size", requestLog.Written),
+	)
+	requestLog.TraceId = span.SpanContext().TraceID().String()
+}
+
+// shutdownOtel flushes spans still waiting in the batcher.
+func shutdownOtel() {
+	if tracerProvider == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *drainTimeoutFlag)
+	defer cancel()
+	err := tracerProvider.Shutdown(ctx)
+	if err != nil {
+		log.Print(err)
+	}
+}
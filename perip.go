@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"net"
+	"net/http"
+	"sync"
+)
+
+var (
+	maxConnsPerIPFlag = flag.Int("max-conns-per-ip", 0, "(optional) -max-conns-per-ip Concurrent requests allowed from one client IP, more get 429 Too Many Requests, 0 means no limit")
+)
+
+// ipCounter tracks in-flight requests per client IP. Entries are deleted
+// once they drop back to zero, so it only ever holds active clients.
+type ipCounter struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// acquire counts a request from ip unless that would exceed limit.
+func (c *ipCounter) acquire(ip string, limit int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts[ip] >= limit {
+		return false
+	}
+	c.counts[ip]++
+	return true
+}
+
+func (c *ipCounter) release(ip string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[ip]--
+	if c.counts[ip] <= 0 {
+		delete(c.counts, ip)
+	}
+}
+
+// maxConnsPerIPHandler answers 429 to a client that already has
+// -max-conns-per-ip requests in flight, which mostly catches download
+// managers splitting one file into many parallel ranges.
+func maxConnsPerIPHandler(handler http.Handler) http.Handler {
+	counter := &ipCounter{counts: map[string]int{}}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		if !counter.acquire(ip, *maxConnsPerIPFlag) {
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		defer counter.release(ip)
+		handler.ServeHTTP(w, r)
+	})
+}
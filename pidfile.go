@@ -0,0 +1,29 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"strconv"
+)
+
+var (
+	pidFileFlag = flag.String("pidfile", "", "(optional) -pidfile File the process ID is written to on startup and removed from on graceful shutdown")
+)
+
+// writePidFile records the process ID in -pidfile, replacing any stale file
+// left behind by a crash.
+func writePidFile() error {
+	err := os.WriteFile(*pidFileFlag, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644)
+	if err != nil {
+		return errors.New("[ERROR] Writing pid file: " + err.Error())
+	}
+	return nil
+}
+
+func removePidFile() {
+	err := os.Remove(*pidFileFlag)
+	if err != nil {
+		print("[ERROR] Removing pid file: " + err.Error())
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"time"
+)
+
+var (
+	timePrecisionFlag = flag.String("time-precision", "millis", "(optional) -time-precision Unit of the DateTime epoch in log entries: seconds, millis, micros or nanos")
+	// dateTimeUnit is the duration of one DateTime tick.
+	dateTimeUnit = time.Millisecond
+)
+
+// dateTimeUnits maps -time-precision values to their tick and the short
+// name logged as DateTimeUnit.
+var dateTimeUnits = map[string]struct {
+	tick time.Duration
+	name string
+}{
+	"seconds": {time.Second, "s"},
+	"millis":  {time.Millisecond, "ms"},
+	"micros":  {time.Microsecond, "us"},
+	"nanos":   {time.Nanosecond, "ns"},
+}
+
+func parseTimePrecision(value string) (time.Duration, error) {
+	unit, ok := dateTimeUnits[value]
+	if !ok {
+		return 0, errors.New("[ERROR] Unknown -time-precision " + value + ", expected seconds, millis, micros or nanos")
+	}
+	return unit.tick, nil
+}
+
+// dateTime returns t as a Unix epoch in -time-precision ticks.
+func dateTime(t time.Time) int64 {
+	return t.UnixNano() / int64(dateTimeUnit)
+}
+
+// dateTimeUnitName is the DateTimeUnit logged alongside DateTime.
+func dateTimeUnitName() string {
+	return dateTimeUnits[*timePrecisionFlag].name
+}
+
+// dateTimeSeconds converts a logged DateTime back to fractional seconds.
+func dateTimeSeconds(value int64) float64 {
+	return float64(value) * dateTimeUnit.Seconds()
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"net/http"
+	"path"
+	"strings"
+)
+
+var (
+	noRangesFlags    stringList
+	noRangesPatterns []string
+)
+
+func init() {
+	flag.Var(&noRangesFlags, "no-ranges", "(optional) -no-ranges Glob of paths, e.g. /reports/* or *.csv, always sent whole with Accept-Ranges: none. Repeatable")
+}
+
+// parseNoRangesPatterns validates the -no-ranges globs and adds the
+// -render-ext files, whose output is generated per request unless
+// -render-cache keeps it.
+func parseNoRangesPatterns(values []string) ([]string, error) {
+	patterns := append([]string(nil), values...)
+	if *renderExtFlag != "" && !*renderCacheFlag {
+		patterns = append(patterns, "*"+*renderExtFlag)
+	}
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, errors.New("[ERROR] Invalid -no-ranges pattern: " + pattern)
+		}
+	}
+	return patterns, nil
+}
+
+// matchNoRanges reports whether urlPath matches a -no-ranges glob.
+func matchNoRanges(urlPath string) bool {
+	return matchGlobs(noRangesPatterns, urlPath)
+}
+
+// matchGlobs reports whether urlPath matches one of patterns. Globs without
+// a slash are matched against the file name alone.
+func matchGlobs(patterns []string, urlPath string) bool {
+	for _, pattern := range patterns {
+		name := urlPath
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(urlPath)
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// noRangesHandler drops Range and If-Range from requests for matching paths
+// so the full body is always sent, and tells clients not to ask for ranges.
+func noRangesHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !matchNoRanges(r.URL.Path) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		r.Header.Del("Range")
+		r.Header.Del("If-Range")
+		// Replaces whatever Accept-Ranges the handler set, e.g.
+		// http.FileServer's bytes, right before the header goes out.
+		handler.ServeHTTP(&headerObserver{ResponseWriter: w, beforeHeader: func(code int) int {
+			w.Header().Set("Accept-Ranges", "none")
+			return code
+		}}, r)
+	})
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rangeTestBody is large and repetitive enough for -gzip to compress it.
+var rangeTestBody = strings.Repeat("0123456789abcdef", 256)
+
+// newRangeTestSite serves data.txt with a fixed modification time and
+// returns it in the form If-Range takes.
+func newRangeTestSite(t *testing.T) (*testSite, string) {
+	t.Helper()
+	site := newTestSite(t, map[string]string{"data.txt": rangeTestBody})
+	modTime := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(site.dir, "data.txt"), modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	return site, modTime.Format(http.TimeFormat)
+}
+
+func TestIfRange(t *testing.T) {
+	stale := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC).Format(http.TimeFormat)
+	t.Cleanup(func() { *gzipFlag = false })
+	for _, gzip := range []bool{false, true} {
+		*gzipFlag = gzip
+		site, lastModified := newRangeTestSite(t)
+
+		tests := []struct {
+			ifRange string
+			status  int
+			body    string
+		}{
+			{lastModified, http.StatusPartialContent, rangeTestBody[:10]},
+			{stale, http.StatusOK, rangeTestBody},
+		}
+		for _, tt := range tests {
+			w := site.get(http.MethodGet, "/data.txt", map[string]string{
+				"Range":           "bytes=0-9",
+				"If-Range":        tt.ifRange,
+				"Accept-Encoding": "identity",
+			})
+			if w.Code != tt.status {
+				t.Errorf("-gzip=%v, If-Range %s: status %d, want %d", gzip, tt.ifRange, w.Code, tt.status)
+			}
+			if w.Body.String() != tt.body {
+				t.Errorf("-gzip=%v, If-Range %s: body of %d bytes, want %d", gzip, tt.ifRange, w.Body.Len(), len(tt.body))
+			}
+			entry := site.lastEntry(t)
+			if entry.Status != tt.status || entry.Written != int64(len(tt.body)) {
+				t.Errorf("-gzip=%v, If-Range %s: logged status %d and %d bytes, want %d and %d", gzip, tt.ifRange, entry.Status, entry.Written, tt.status, len(tt.body))
+			}
+		}
+	}
+}
+
+// TestIfRangeCompressed checks that -gzip leaves a 206 alone and that the
+// bytes logged for a compressed 200 are the compressed ones.
+func TestIfRangeCompressed(t *testing.T) {
+	*gzipFlag = true
+	t.Cleanup(func() { *gzipFlag = false })
+	site, lastModified := newRangeTestSite(t)
+
+	w := site.get(http.MethodGet, "/data.txt", map[string]string{
+		"Range":           "bytes=0-9",
+		"If-Range":        lastModified,
+		"Accept-Encoding": "gzip",
+	})
+	if w.Code != http.StatusPartialContent || w.Header().Get("Content-Encoding") != "" || w.Body.String() != rangeTestBody[:10] {
+		t.Errorf("matching If-Range: status %d, Content-Encoding %q, want an uncompressed 206", w.Code, w.Header().Get("Content-Encoding"))
+	}
+
+	w = site.get(http.MethodGet, "/data.txt", map[string]string{
+		"Range":           "bytes=0-9",
+		"If-Range":        "\"stale\"",
+		"Accept-Encoding": "gzip",
+	})
+	if w.Code != http.StatusOK || w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("stale If-Range: status %d, Content-Encoding %q, want a compressed 200", w.Code, w.Header().Get("Content-Encoding"))
+	}
+	entry := site.lastEntry(t)
+	if entry.Status != http.StatusOK || entry.Written != int64(w.Body.Len()) {
+		t.Errorf("stale If-Range: logged status %d and %d bytes, want 200 and %d", entry.Status, entry.Written, w.Body.Len())
+	}
+}
+
+func TestNoRangesIgnoresIfRange(t *testing.T) {
+	noRangesPatterns = []string{"*.txt"}
+	t.Cleanup(func() { noRangesPatterns = nil })
+	site, lastModified := newRangeTestSite(t)
+
+	w := site.get(http.MethodGet, "/data.txt", map[string]string{
+		"Range":    "bytes=0-9",
+		"If-Range": lastModified,
+	})
+	if w.Code != http.StatusOK || w.Body.String() != rangeTestBody || w.Header().Get("Accept-Ranges") != "none" {
+		t.Errorf("status %d, Accept-Ranges %q, want the whole file with none", w.Code, w.Header().Get("Accept-Ranges"))
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"net/url"
+	"strings"
+)
+
+var (
+	logNoQueryFlag = flag.Bool("log-no-query", false, "(optional) -log-no-query Drops the query string from logged URLs")
+	logRedactFlag  = flag.String("log-redact", "", "(optional) -log-redact Comma separated query parameter names whose values are masked in logged URLs, e.g. token,sig")
+	redactedParams map[string]bool
+)
+
+func parseRedactedParams(value string) map[string]bool {
+	params := map[string]bool{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			params[name] = true
+		}
+	}
+	return params
+}
+
+// logQuery applies -log-no-query and -log-redact to a request target such as
+// r.RequestURI or r.URL.String(), leaving the path untouched.
+func logQuery(target string) string {
+	base, query, ok := strings.Cut(target, "?")
+	if !ok {
+		return target
+	}
+	if *logNoQueryFlag {
+		return base
+	}
+	if len(redactedParams) == 0 {
+		return target
+	}
+	pairs := strings.Split(query, "&")
+	for i, pair := range pairs {
+		key, _, _ := strings.Cut(pair, "=")
+		name, err := url.QueryUnescape(key)
+		if err != nil {
+			name = key
+		}
+		if redactedParams[name] {
+			pairs[i] = key + "=REDACTED"
+		}
+	}
+	return base + "?" + strings.Join(pairs, "&")
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"time"
+)
+
+var (
+	renderExtFlag   = flag.String("render-ext", "", "(optional) -render-ext Files with this extension, e.g. .gohtml, are executed as html/template instead of served verbatim")
+	renderCacheFlag = flag.Bool("render-cache", false, "(optional) -render-cache Renders each -render-ext template once until the file changes and serves the result with Range and conditional request support. .Now and .Request are then those of the request that rendered it")
+	// version is reported to templates; set it at build time with
+	// -ldflags "-X main.version=1.2.3".
+	version = "dev"
+)
+
+// templateData is the context rendered templates are executed with.
+type templateData struct {
+	Now     time.Time
+	Version string
+	Request *http.Request
+}
+
+// renderHandler executes files ending in -render-ext as HTML templates and
+// hands every other request to handler.
+func renderHandler(handler http.Handler, root http.FileSystem) http.Handler {
+	base := servedPathBase(root)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if path.Ext(r.URL.Path) != *renderExtFlag {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		f, err := root.Open(path.Clean(r.URL.Path))
+		if err != nil {
+			if os.IsNotExist(err) {
+				http.NotFound(w, r)
+				return
+			}
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		defer f.Close()
+		noteServedPath(r, base, r.URL.Path)
+		if !*renderCacheFlag {
+			page, err := renderTemplate(f, r)
+			if err != nil {
+				renderError(w, r, err)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			serveGenerated(w, r, time.Time{}, page)
+			return
+		}
+		info, err := f.Stat()
+		if err != nil {
+			renderError(w, r, err)
+			return
+		}
+		page, err := renderedTemplates.get(path.Join(base, r.URL.Path), info, func() ([]byte, error) { return renderTemplate(f, r) })
+		if err != nil {
+			renderError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		serveGenerated(w, r, info.ModTime(), page)
+	})
+}
+
+// serveGenerated sends page, which the server produced rather than read
+// from a file, with a weak ETag from its hash so an unchanged page can be
+// revalidated with If-None-Match. It is weak because the same page may go
+// out gzipped or not. modTime is that of the source, or zero without one.
+// The caller sets Content-Type.
+func serveGenerated(w http.ResponseWriter, r *http.Request, modTime time.Time, page []byte) {
+	sum := sha256.Sum256(page)
+	w.Header().Set("ETag", `W/"`+hex.EncodeToString(sum[:12])+`"`)
+	http.ServeContent(w, r, "", modTime, bytes.NewReader(page))
+}
+
+// renderedTemplates holds each -render-cache page until its template
+// changes.
+var renderedTemplates = &pageCache{pages: map[string]cachedPage{}}
+
+// renderTemplate executes the template in f for r.
+func renderTemplate(f io.Reader, r *http.Request) ([]byte, error) {
+	src, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New(path.Base(r.URL.Path)).Parse(string(src))
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	err = tmpl.Execute(&out, templateData{Now: time.Now(), Version: version, Request: r})
+	if err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func renderError(w http.ResponseWriter, r *http.Request, err error) {
+	log.Printf("[ERROR] Rendering %s: %s", r.URL.Path, err)
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
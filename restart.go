@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// listenersEnv tells a restarted process which address each listening
+// socket it inherited is bound to, in file descriptor order from 3.
+const listenersEnv = "GOHTTPSERVER_LISTENERS"
+
+// namedListener is a listener opened by listen, kept so a restart can hand
+// its socket to the new process.
+type namedListener struct {
+	addr string
+	ln   net.Listener
+}
+
+var (
+	openListeners []namedListener
+	// inheritedListeners holds the sockets passed down by the previous
+	// process that no listen call has claimed yet.
+	inheritedListeners map[string]net.Listener
+	// restarting is set while a new process started by a restart is
+	// coming up, and stays set once it has taken over.
+	restarting atomic.Bool
+)
+
+// loadInheritedListeners picks up the sockets listed in listenersEnv. It is
+// a no-op for a process that was not started by a restart.
+func loadInheritedListeners() error {
+	value := os.Getenv(listenersEnv)
+	if value == "" {
+		return nil
+	}
+	os.Unsetenv(listenersEnv)
+	inheritedListeners = map[string]net.Listener{}
+	for i, addr := range strings.Split(value, ",") {
+		f := os.NewFile(uintptr(3+i), addr)
+		ln, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		inheritedListeners[addr] = ln
+	}
+	return nil
+}
+
+// takeInheritedListener returns the inherited socket for addr, if any.
+func takeInheritedListener(addr string) (net.Listener, bool) {
+	ln, ok := inheritedListeners[addr]
+	if ok {
+		delete(inheritedListeners, addr)
+	}
+	return ln, ok
+}
+
+// closeUnusedListeners closes inherited sockets for addresses this process
+// no longer listens on, e.g. after -admin-addr was dropped.
+func closeUnusedListeners() {
+	for addr, ln := range inheritedListeners {
+		ln.Close()
+		delete(inheritedListeners, addr)
+	}
+}
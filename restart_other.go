@@ -0,0 +1,8 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package main
+
+// restartOnSignal is a no-op, SIGUSR2 restarts need Unix signals.
+func restartOnSignal() {}
+
+func notifyParent() {}
@@ -0,0 +1,91 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package main
+
+import (
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// restartOnSignal starts a new copy of the server on SIGUSR2, handing it
+// every listening socket. This process keeps serving until the new one is
+// ready and sends it SIGTERM, so no connection is refused in between.
+func restartOnSignal() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGUSR2)
+	for range sig {
+		if !restarting.CompareAndSwap(false, true) {
+			print("[WARN] Restart already in progress")
+			continue
+		}
+		process, err := startRestartedProcess()
+		if err != nil {
+			print("[ERROR] Restarting: " + err.Error())
+			restarting.Store(false)
+			continue
+		}
+		print("[INFO] Restarting, started new process " + strconv.Itoa(process.Pid))
+		go waitRestartedProcess(process)
+	}
+}
+
+// startRestartedProcess runs the binary again with the same arguments. The
+// listeners are passed as extra files, which become descriptors 3 onwards.
+func startRestartedProcess() (*os.Process, error) {
+	binary, err := exec.LookPath(os.Args[0])
+	if err != nil {
+		return nil, err
+	}
+	files := []*os.File{os.Stdin, os.Stdout, os.Stderr}
+	addrs := make([]string, 0, len(openListeners))
+	for _, l := range openListeners {
+		f, err := l.ln.(interface{ File() (*os.File, error) }).File()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		files = append(files, f)
+		addrs = append(addrs, l.addr)
+	}
+	env := append(os.Environ(), listenersEnv+"="+strings.Join(addrs, ","))
+	return os.StartProcess(binary, os.Args, &os.ProcAttr{Env: env, Files: files})
+}
+
+// waitRestartedProcess reaps the new process. If it exits before taking
+// over, this process carries on serving and can be restarted again.
+func waitRestartedProcess(process *os.Process) {
+	state, err := process.Wait()
+	if serverState.Load() == stateDraining {
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Restarted process: %v", err)
+	} else {
+		log.Printf("[ERROR] Restarted process exited before taking over: %v", state)
+	}
+	restarting.Store(false)
+	if *pidFileFlag != "" {
+		err = writePidFile()
+		if err != nil {
+			log.Print(err)
+		}
+	}
+}
+
+// notifyParent tells the process that started this one in a restart that
+// it can stop accepting, once every listener is up.
+func notifyParent() {
+	if inheritedListeners == nil {
+		return
+	}
+	closeUnusedListeners()
+	err := syscall.Kill(os.Getppid(), syscall.SIGTERM)
+	if err != nil {
+		log.Printf("[ERROR] Notifying previous process: %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+var (
+	rewriteFlags stringList
+	rewriteRules []rewriteRule
+)
+
+func init() {
+	flag.Var(&rewriteFlags, "rewrite", "(optional) -rewrite 'from=to' Rewrites a path prefix before it is served, or a regex when from starts with ~. Repeatable")
+}
+
+// rewriteRule replaces a path prefix, or every match of pattern when it is
+// set, before the request reaches the file server.
+type rewriteRule struct {
+	from    string
+	to      string
+	pattern *regexp.Regexp
+}
+
+func (rule rewriteRule) apply(path string) (string, bool) {
+	if rule.pattern != nil {
+		if !rule.pattern.MatchString(path) {
+			return path, false
+		}
+		return rule.pattern.ReplaceAllString(path, rule.to), true
+	}
+	if !strings.HasPrefix(path, rule.from) {
+		return path, false
+	}
+	return rule.to + strings.TrimPrefix(path, rule.from), true
+}
+
+func parseRewriteRules(values []string) ([]rewriteRule, error) {
+	var rules []rewriteRule
+	for _, value := range values {
+		from, to, ok := strings.Cut(value, "=")
+		if !ok || from == "" {
+			return nil, errors.New("[ERROR] Rewrite must be in the form from=to: " + value)
+		}
+		rule := rewriteRule{from: from, to: to}
+		if strings.HasPrefix(from, "~") {
+			pattern, err := regexp.Compile(from[1:])
+			if err != nil {
+				return nil, errors.New("[ERROR] Rewrite regex invalid: " + err.Error())
+			}
+			rule.pattern = pattern
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// rewriteHandler applies the first matching rewrite rule to r.URL.Path. The
+// original path stays in RequestURI and the rewritten one is noted for the
+// URL field, so the access log shows both.
+func rewriteHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, rule := range rewriteRules {
+			path, ok := rule.apply(r.URL.Path)
+			if !ok {
+				continue
+			}
+			r.URL.Path = "/" + strings.TrimLeft(path, "/")
+			r.URL.RawPath = ""
+			if notes := notesFrom(r); notes != nil {
+				notes.rewrittenPath = *basePathFlag + r.URL.Path
+			}
+			break
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"net/http"
+	"os"
+	"time"
+)
+
+var (
+	robotsFlag = flag.String("robots", "", "(optional) -robots robots.txt served at /robots.txt when the site has none: a file path, or deny to disallow all crawling")
+	robotsTxt  []byte
+)
+
+const denyAllRobots = "User-agent: *\nDisallow: /\n"
+
+// setupRobots reads -robots once at startup.
+func setupRobots() error {
+	if *robotsFlag == "deny" {
+		robotsTxt = []byte(denyAllRobots)
+		return nil
+	}
+	body, err := os.ReadFile(*robotsFlag)
+	if err != nil {
+		return err
+	}
+	robotsTxt = body
+	return nil
+}
+
+// robotsHandler answers /robots.txt with -robots unless root has a
+// robots.txt of its own, which handler serves. The startup time stands in
+// as the modification time for conditional requests.
+func robotsHandler(handler http.Handler, root http.FileSystem) http.Handler {
+	modTime := time.Now()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" || fileExists(root, "/robots.txt") {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		http.ServeContent(w, r, "robots.txt", modTime, bytes.NewReader(robotsTxt))
+	})
+}
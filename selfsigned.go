@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"flag"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	selfSignedFlag    = flag.Bool("self-signed", false, "(optional) -self-signed DEV ONLY. Serves TLS with a generated self-signed certificate for localhost and this machine's IPs")
+	selfSignedDirFlag = flag.String("self-signed-dir", "", "(optional) -self-signed-dir Directory to store the -self-signed certificate in and reuse it from across restarts")
+)
+
+// selfSignedCertificate returns the certificate for -self-signed, loading it
+// from -self-signed-dir when a previous run saved one there.
+func selfSignedCertificate() (tls.Certificate, error) {
+	if *selfSignedDirFlag == "" {
+		certPEM, keyPEM, err := generateSelfSigned()
+		if err != nil {
+			return tls.Certificate{}, err
+		}
+		return tls.X509KeyPair(certPEM, keyPEM)
+	}
+
+	certPath := filepath.Join(*selfSignedDirFlag, "cert.pem")
+	keyPath := filepath.Join(*selfSignedDirFlag, "key.pem")
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err == nil {
+		return cert, nil
+	}
+
+	certPEM, keyPEM, err := generateSelfSigned()
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	err = os.MkdirAll(*selfSignedDirFlag, 0700)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	err = os.WriteFile(certPath, certPEM, 0644)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	err = os.WriteFile(keyPath, keyPEM, 0600)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	print("[INFO] Saved self-signed certificate to " + certPath)
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// generateSelfSigned creates a one year ECDSA certificate with SANs for
+// localhost and every address on the machine's interfaces.
+func generateSelfSigned() (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"goHttpServer self-signed"}, CommonName: "localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		template.DNSNames = append(template.DNSNames, hostname)
+	}
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() {
+				template.IPAddresses = append(template.IPAddresses, ipNet.IP)
+			}
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"path"
+	"path/filepath"
+)
+
+var (
+	logServedPathFlag = flag.Bool("log-served-path", false, "(optional) -log-served-path Adds the absolute path of the file each response was read from to JSON, slog, logfmt and GELF entries as ServedPath")
+)
+
+// servedPathBase returns the absolute location files in root are resolved
+// against: the directory for http.Dir, and the archive for -archive, whose
+// members are shown as if the archive were a directory.
+func servedPathBase(root http.FileSystem) string {
+	base := *archiveFlag
+	if dir, ok := rootDir(root); ok {
+		base = string(dir)
+	}
+	abs, err := filepath.Abs(base)
+	if err != nil {
+		return base
+	}
+	return abs
+}
+
+// noteServedPath records the file name in root as the one the response to
+// r is read from.
+func noteServedPath(r *http.Request, base, name string) {
+	notes := notesFrom(r)
+	if notes == nil || !*logServedPathFlag {
+		return
+	}
+	notes.servedPath = filepath.Join(base, filepath.FromSlash(path.Clean("/"+name)))
+}
+
+// fileServerHandler is http.FileServer over root that notes which file it
+// opens for the request, index.html included, when -log-served-path is set.
+func fileServerHandler(root http.FileSystem) http.Handler {
+	if !*logServedPathFlag {
+		return http.FileServer(root)
+	}
+	base := servedPathBase(root)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.FileServer(servedPathFS{FileSystem: root, r: r, base: base}).ServeHTTP(w, r)
+	})
+}
+
+// servedPathFS notes each regular file opened through it for one request.
+type servedPathFS struct {
+	http.FileSystem
+	r    *http.Request
+	base string
+}
+
+func (fs servedPathFS) Open(name string) (http.File, error) {
+	f, err := fs.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := f.Stat(); err == nil && info.Mode().IsRegular() {
+		noteServedPath(fs.r, fs.base, name)
+	}
+	return f, nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+var (
+	retryAfterFlag         = flag.Int("retry-after", 5, "(optional) -retry-after Seconds sent in Retry-After on 503s while starting up or shutting down")
+	drainDelayFlag         = flag.Duration("drain-delay", 0, "(optional) -drain-delay How long to keep answering 503 after a shutdown signal before closing listeners")
+	drainTimeoutFlag       = flag.Duration("drain-timeout", 30*time.Second, "(optional) -drain-timeout Max time to wait for in-flight requests on shutdown")
+	drainWaitDownloadsFlag = flag.Duration("drain-wait-downloads", 0, "(optional) -drain-wait-downloads Extra time after -drain-timeout that responses already sending a 200 or 206 body get to finish on shutdown")
+)
+
+const (
+	stateStarting int32 = iota
+	stateReady
+	stateDraining
+)
+
+// serverState moves from starting to ready once every listener is up, and
+// to draining as soon as a shutdown signal arrives.
+var serverState atomic.Int32
+
+// availabilityHandler answers 503 with a Retry-After header until the
+// server is ready and again once it starts draining, so clients back off
+// instead of hitting connection errors.
+func availabilityHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := serverState.Load()
+		if state == stateReady {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		if state == stateDraining {
+			w.Header().Set("Connection", "close")
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(*retryAfterFlag))
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+	})
+}
+
+// shutdownOnSignal waits for SIGINT or SIGTERM, keeps answering 503 for
+// -drain-delay, then gracefully shuts down every server. done is closed
+// once all of them have finished. When the SIGTERM comes from a restarted
+// process that already accepts on the same sockets, the delay is skipped.
+func shutdownOnSignal(servers []*http.Server, done chan<- struct{}) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	<-sig
+
+	serverState.Store(stateDraining)
+	handover := restarting.Load()
+	if handover {
+		print("[INFO] Handing over to the restarted process, draining connections")
+	} else {
+		print("[INFO] Shutting down, draining connections")
+		time.Sleep(*drainDelayFlag)
+	}
+
+	// Downloads finish while the listeners stay open and keep answering
+	// 503, so new clients are told to retry rather than refused. On a
+	// handover the restarted process is accepting on the same sockets,
+	// so the listeners close first and leave new clients to it.
+	deadline := time.Now().Add(*drainTimeoutFlag)
+	if *drainWaitDownloadsFlag > 0 && !handover {
+		waitForDownloads(deadline.Add(*drainWaitDownloadsFlag))
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	for _, server := range servers {
+		err := server.Shutdown(ctx)
+		if err != nil {
+			log.Print(err)
+		}
+	}
+	if *drainWaitDownloadsFlag > 0 && handover {
+		waitForDownloads(time.Now().Add(*drainWaitDownloadsFlag))
+	}
+	close(done)
+}
+
+// waitForDownloads waits until no 200 or 206 body is still being sent, or
+// deadline passes.
+func waitForDownloads(deadline time.Time) {
+	if stats.downloads.Load() > 0 {
+		print("[INFO] Waiting up to " + time.Until(deadline).Round(time.Second).String() + " for " + strconv.FormatInt(stats.downloads.Load(), 10) + " downloads to finish")
+	}
+	for stats.downloads.Load() > 0 && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+}
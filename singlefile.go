@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+var (
+	fileFlag      = flag.String("file", "", "(optional) -file Serves only this file, at /<name> and at /, instead of a directory")
+	fileIndexFlag = flag.String("file-index", "", "(optional) -file-index HTML page served at / in -file mode, e.g. a landing page linking to the file, which then stays at /<name>")
+)
+
+// singleFileHandler serves -file at /<name>, and at / either -file-index or
+// the file itself as an attachment so it still downloads under its name.
+// Every other path is 404.
+func singleFileHandler() http.Handler {
+	root := http.Dir(filepath.Dir(*fileFlag))
+	base := servedPathBase(root)
+	name := "/" + filepath.Base(*fileFlag)
+	indexRoot := http.Dir(filepath.Dir(*fileIndexFlag))
+	indexBase := servedPathBase(indexRoot)
+	indexName := "/" + filepath.Base(*fileIndexFlag)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == name:
+			serveFile(w, r, root, base, name)
+		case r.URL.Path == "/" && *fileIndexFlag != "":
+			serveFile(w, r, indexRoot, indexBase, indexName)
+		case r.URL.Path == "/":
+			w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name[1:]}))
+			serveFile(w, r, root, base, name)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func checkFileFlags() error {
+	if *fileIndexFlag != "" && *fileFlag == "" {
+		return errors.New("[ERROR] -file-index requires -file")
+	}
+	if *fileFlag == "" {
+		return nil
+	}
+	if *serveDirectoryFlag != "" || *archiveFlag != "" || len(vhostFlags) > 0 || *upstreamFlag != "" {
+		return errors.New("[ERROR] -file serves one file and cannot be combined with -d, -archive, -vhost or -upstream")
+	}
+	for _, path := range []string{*fileFlag, *fileIndexFlag} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return errors.New("[ERROR] " + err.Error())
+		}
+		if !info.Mode().IsRegular() {
+			return errors.New("[ERROR] Not a regular file: " + path)
+		}
+	}
+	return nil
+}
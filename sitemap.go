@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	sitemapFlag     = flag.Bool("sitemap", false, "(optional) -sitemap Generates /sitemap.xml listing every HTML file when no sitemap.xml exists on disk")
+	sitemapHostFlag = flag.String("sitemap-host", "", "(optional) -sitemap-host Host the -sitemap URLs of the -d site point at, e.g. example.com. Defaults to the first -host, -vhost sites use their own name")
+	// sitemapHost is the host in the sitemap URLs of the -d site.
+	sitemapHost string
+)
+
+// sitemapMaxURLs is the most URLs the sitemap protocol allows in one file.
+const sitemapMaxURLs = 50000
+
+// parseSitemapHost picks the host for sitemap URLs from the command line,
+// never from a request, since a client could put any name in its Host
+// header and search engines would take the URLs as they are.
+func parseSitemapHost() (string, error) {
+	if !*sitemapFlag {
+		if *sitemapHostFlag != "" {
+			return "", errors.New("[ERROR] -sitemap-host requires -sitemap")
+		}
+		return "", nil
+	}
+	if *sitemapHostFlag != "" {
+		return *sitemapHostFlag, nil
+	}
+	if len(hostFlags) > 0 {
+		return hostFlags[0], nil
+	}
+	return "", errors.New("[ERROR] -sitemap requires -sitemap-host or -host, the host its URLs point at")
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// sitemapPage is an HTML file found by the walk, with the URL path it is
+// served at.
+type sitemapPage struct {
+	path    string
+	modTime time.Time
+}
+
+// sitemapHandler answers /sitemap.xml with a sitemap of the HTML files in
+// root, unless root has a sitemap.xml of its own, which handler serves. The
+// walk is reused until one of the directories it listed changes.
+func sitemapHandler(handler http.Handler, root http.FileSystem) http.Handler {
+	var (
+		mu    sync.Mutex
+		pages []sitemapPage
+		dirs  map[string]time.Time
+	)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/sitemap.xml" || fileExists(root, "/sitemap.xml") {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		mu.Lock()
+		if len(dirs) == 0 || sitemapDirsChanged(root, dirs) {
+			pages, dirs = walkSitemapPages(root)
+		}
+		current := pages
+		mu.Unlock()
+
+		// A -vhost site is only reached under its own name, every other
+		// request is for the -d site.
+		host := sitemapHost
+		if name := normalizeHost(r.Host); vhosts[name] != "" {
+			host = name
+		}
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		urlSet := sitemapURLSet{Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9", URLs: make([]sitemapURL, 0, len(current))}
+		for _, page := range current {
+			loc := url.URL{Scheme: scheme, Host: host, Path: *basePathFlag + page.path}
+			urlSet.URLs = append(urlSet.URLs, sitemapURL{Loc: loc.String(), LastMod: page.modTime.UTC().Format(time.RFC3339)})
+		}
+		var out bytes.Buffer
+		out.WriteString(xml.Header)
+		enc := xml.NewEncoder(&out)
+		enc.Indent("", "  ")
+		enc.Encode(urlSet)
+		out.WriteString("\n")
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		serveGenerated(w, r, time.Time{}, out.Bytes())
+	})
+}
+
+// walkSitemapPages lists the .html and .htm files under root, skipping
+// hidden files and directories. An index.html is listed as its directory.
+// It also returns the modification time of every directory it listed.
+func walkSitemapPages(root http.FileSystem) ([]sitemapPage, map[string]time.Time) {
+	var pages []sitemapPage
+	dirs := map[string]time.Time{}
+	var walk func(dir string)
+	walk = func(dir string) {
+		f, err := root.Open(dir)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			return
+		}
+		entries, err := f.Readdir(-1)
+		if err != nil {
+			return
+		}
+		dirs[dir] = info.ModTime()
+		for _, entry := range entries {
+			name := entry.Name()
+			if strings.HasPrefix(name, ".") {
+				continue
+			}
+			urlPath := path.Join(dir, name)
+			if entry.IsDir() {
+				walk(urlPath)
+				continue
+			}
+			ext := strings.ToLower(path.Ext(name))
+			if ext != ".html" && ext != ".htm" {
+				continue
+			}
+			if name == "index.html" {
+				urlPath = strings.TrimSuffix(urlPath, "index.html")
+			}
+			pages = append(pages, sitemapPage{path: urlPath, modTime: entry.ModTime()})
+		}
+	}
+	walk("/")
+	sort.Slice(pages, func(i, j int) bool { return pages[i].path < pages[j].path })
+	if len(pages) > sitemapMaxURLs {
+		log.Printf("[WARN] Sitemap truncated to %d of %d pages", sitemapMaxURLs, len(pages))
+		pages = pages[:sitemapMaxURLs]
+	}
+	return pages, dirs
+}
+
+// sitemapDirsChanged reports whether a directory the walk listed has been
+// modified or removed since. Adding, removing or renaming a file changes
+// its directory's modification time, editing one in place does not.
+func sitemapDirsChanged(root http.FileSystem, dirs map[string]time.Time) bool {
+	for dir, modTime := range dirs {
+		f, err := root.Open(dir)
+		if err != nil {
+			return true
+		}
+		info, err := f.Stat()
+		f.Close()
+		if err != nil || !info.ModTime().Equal(modTime) {
+			return true
+		}
+	}
+	return false
+}
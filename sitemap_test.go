@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSitemap(t *testing.T) {
+	*sitemapFlag = true
+	sitemapHost = "example.com"
+	t.Cleanup(func() { *sitemapFlag, sitemapHost = false, "" })
+	site := newTestSite(t, map[string]string{"index.html": "home", "docs/a.html": "a"})
+
+	w := site.get(http.MethodGet, "/sitemap.xml", map[string]string{"Host": "attacker.example"})
+	body := w.Body.String()
+	if !strings.Contains(body, "<loc>http://example.com/docs/a.html</loc>") || strings.Contains(body, "attacker") {
+		t.Fatalf("sitemap does not use -sitemap-host:\n%s", body)
+	}
+
+	docs := filepath.Join(site.dir, "docs")
+	if err := os.WriteFile(filepath.Join(docs, "b.html"), []byte("b"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Make sure the directory's modification time moves even on file
+	// systems with coarse timestamps.
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(docs, later, later); err != nil {
+		t.Fatal(err)
+	}
+	w = site.get(http.MethodGet, "/sitemap.xml", nil)
+	if !strings.Contains(w.Body.String(), "<loc>http://example.com/docs/b.html</loc>") {
+		t.Errorf("page added to a changed directory is missing:\n%s", w.Body)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+)
+
+// setupSlog installs a JSON slog handler as the default logger, writing to
+// the -l log file when one is given and to stderr otherwise.
+func setupSlog() {
+	var w io.Writer = os.Stderr
+	if accessLog != nil {
+		w = accessLog
+	}
+	slog.SetDefault(slog.New(slog.NewJSONHandler(w, slogOptions())))
+}
+
+// slogOptions renders the time of every record in the -utc zone.
+func slogOptions() *slog.HandlerOptions {
+	return &slog.HandlerOptions{ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+		if a.Key == slog.TimeKey && len(groups) == 0 {
+			a.Value = slog.TimeValue(logTime(a.Value.Time()))
+		}
+		return a
+	}}
+}
+
+// requestLogAttrs maps every RequestLog field to a slog attribute keyed by
+// the same name the -j JSON output uses.
+func requestLogAttrs(requestLog RequestLog) []any {
+	attrs := []any{
+		slog.String("RemoteAddr", requestLog.RemoteAddr),
+		slog.String("URL", requestLog.URL),
+		slog.String("Host", requestLog.Host),
+		slog.String("UserAgent", requestLog.UserAgent),
+		slog.String("Referer", requestLog.Referer),
+		slog.String("Method", requestLog.Method),
+		slog.String("RequestURI", requestLog.RequestURI),
+		slog.String("Protocol", requestLog.Protocol),
+		slog.Int("Status", requestLog.Status),
+		slog.Int64("Written", requestLog.Written),
+		slog.Int64("ContentLength", requestLog.ContentLength),
+		slog.Int64("DateTime", requestLog.DateTime),
+		slog.String("DateTimeUnit", requestLog.DateTimeUnit),
+		slog.Int64("TimeTaken", requestLog.TimeTaken),
+		slog.Bool("ClientClosed", requestLog.ClientClosed),
+		slog.Bool("IsBot", requestLog.IsBot),
+		slog.Bool("Upstream", requestLog.Upstream),
+		slog.String("TransferEncoding", requestLog.TransferEncoding),
+	}
+	if requestLog.TraceId != "" {
+		attrs = append(attrs, slog.String("TraceId", requestLog.TraceId))
+	}
+	if requestLog.ServedPath != "" {
+		attrs = append(attrs, slog.String("ServedPath", requestLog.ServedPath))
+	}
+	if requestLog.GRPCStatus != "" {
+		attrs = append(attrs, slog.String("GRPCStatus", requestLog.GRPCStatus))
+	}
+	if len(requestLog.Labels) > 0 {
+		attrs = append(attrs, sortedGroup("Labels", requestLog.Labels))
+	}
+	if len(requestLog.Headers) > 0 {
+		attrs = append(attrs, sortedGroup("Headers", requestLog.Headers))
+	}
+	return attrs
+}
+
+// sortedGroup turns a map into a slog group with its keys in order, so
+// entries always list them the same way.
+func sortedGroup(name string, values map[string]string) slog.Attr {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	attrs := make([]any, 0, len(keys))
+	for _, key := range keys {
+		attrs = append(attrs, slog.String(key, values[key]))
+	}
+	return slog.Group(name, attrs...)
+}
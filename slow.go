@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"log/slog"
+	"os"
+	"time"
+)
+
+var (
+	slowThresholdFlag = flag.Duration("slow-threshold", 0, "(optional) -slow-threshold Requests taking longer are also logged as a WARN line, 0 disables")
+	slowLogFlag       = flag.String("slow-log", "", "(optional) -slow-log File for -slow-threshold warnings instead of stderr")
+	slowLogger        *log.Logger
+)
+
+// setupSlowLog points slow request warnings at stderr or -slow-log, kept
+// apart from the access log so they are easy to alert on.
+func setupSlowLog() error {
+	if *slowLogFlag == "" {
+		slowLogger = log.New(os.Stderr, "", log.Flags())
+		return nil
+	}
+	f, err := openLogFile(*slowLogFlag, logFileMode, logDirMode)
+	if err != nil {
+		return err
+	}
+	hangupFiles = append(hangupFiles, f)
+	slowLogger = log.New(f, "", log.Flags())
+	return nil
+}
+
+func logSlowRequest(requestLog RequestLog, duration time.Duration) {
+	if *slogFlag && *slowLogFlag == "" {
+		slog.Warn("slow request", "Method", requestLog.Method, "URL", requestLog.URL, "Status", requestLog.Status, "Duration", duration.String())
+		return
+	}
+	slowLogger.Printf("[WARN] Slow request %s %s %d took %s", requestLog.Method, requestLog.URL, requestLog.Status, duration)
+}
@@ -0,0 +1,20 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package main
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+func controlSocket(network, address string, c syscall.RawConn) error {
+	if *reusePortFlag {
+		return errors.New("[ERROR] -reuseport is only supported on Linux and BSD")
+	}
+	return nil
+}
+
+func setBacklog(ln net.Listener, backlog int) error {
+	return errors.New("[ERROR] -backlog is only supported on Linux and BSD")
+}
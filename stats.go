@@ -0,0 +1,82 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// serverStats aggregates the per request data logHandler already computes.
+type serverStats struct {
+	mu       sync.Mutex
+	requests int64
+	written  int64
+	statuses map[int]int64
+	// perSecond counts requests in each of the last 60 seconds, slot
+	// second%60, with secondOf telling which second a slot holds.
+	perSecond [60]int64
+	secondOf  [60]int64
+	inFlight  atomic.Int64
+	// downloads counts the in-flight requests already sending a 200 or
+	// 206 body, which -drain-wait-downloads waits for on shutdown.
+	downloads atomic.Int64
+	// openFiles counts the files opened from the site and not yet closed,
+	// tracked only with -max-open-files.
+	openFiles atomic.Int64
+}
+
+var stats = &serverStats{statuses: map[int]int64{}}
+
+// startTime is when the process started, reported as uptime by /stats.
+var startTime = time.Now()
+
+func (s *serverStats) record(requestLog RequestLog) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	s.written += requestLog.Written
+	s.statuses[requestLog.Status]++
+	now := time.Now().Unix()
+	slot := now % 60
+	if s.secondOf[slot] != now {
+		s.secondOf[slot] = now
+		s.perSecond[slot] = 0
+	}
+	s.perSecond[slot]++
+}
+
+// lastMinute returns how many requests finished in the last 60 seconds.
+func (s *serverStats) lastMinute() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().Unix()
+	var count int64
+	for slot, second := range s.secondOf {
+		if now-second < 60 {
+			count += s.perSecond[slot]
+		}
+	}
+	return count
+}
+
+// snapshot returns a copy of the counters that is safe to read without the lock.
+func (s *serverStats) snapshot() (requests, written int64, statuses map[int]int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses = make(map[int]int64, len(s.statuses))
+	for code, count := range s.statuses {
+		statuses[code] = count
+	}
+	return s.requests, s.written, statuses
+}
+
+// reset zeroes the counters. In-flight requests are left alone since they
+// are still running.
+func (s *serverStats) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = 0
+	s.written = 0
+	s.statuses = map[int]int64{}
+	s.perSecond = [60]int64{}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	summaryIntervalFlag = flag.Duration("summary-interval", 0, "(optional) -summary-interval Logs a [SUMMARY] line with requests, rate, bytes and statuses since the last one this often, 0 disables")
+)
+
+// logSummaries logs the change in the request counters every interval.
+// A reset through /stats/reset in between restarts the window from zero.
+func logSummaries(interval time.Duration) {
+	prevRequests, prevWritten, prevStatuses := stats.snapshot()
+	for range time.Tick(interval) {
+		requests, written, statuses := stats.snapshot()
+		if requests < prevRequests {
+			prevRequests, prevWritten, prevStatuses = 0, 0, map[int]int64{}
+		}
+
+		codes := make([]int, 0, len(statuses))
+		for code := range statuses {
+			if statuses[code] > prevStatuses[code] {
+				codes = append(codes, code)
+			}
+		}
+		sort.Ints(codes)
+		breakdown := make([]string, len(codes))
+		for i, code := range codes {
+			breakdown[i] = fmt.Sprintf("%d:%d", code, statuses[code]-prevStatuses[code])
+		}
+
+		count := requests - prevRequests
+		log.Printf("[SUMMARY] requests=%d rps=%.2f written=%d statuses=%s", count, float64(count)/interval.Seconds(), written-prevWritten, strings.Join(breakdown, ","))
+		prevRequests, prevWritten, prevStatuses = requests, written, statuses
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+var (
+	bandwidthLimitFlag   = flag.Int64("bandwidth-limit", 0, "(optional) -bandwidth-limit Max bytes per second written across all responses, 0 means unlimited")
+	perConnBandwidthFlag = flag.Int64("per-conn-bandwidth", 0, "(optional) -per-conn-bandwidth Max bytes per second written to a single response, 0 means unlimited")
+	throttleMinSizeFlag  = flag.Int64("throttle-min-size", 64*1024, "(optional) -throttle-min-size Responses with a smaller Content-Length skip the per connection throttle")
+	globalBandwidth      *rate.Limiter
+)
+
+// throttleChunk is the largest slice handed to the underlying writer in one
+// go, so a single big Write cannot exceed the rate in a burst.
+const throttleChunk = 32 * 1024
+
+// newByteLimiter limits to bytesPerSecond with up to one second's worth of
+// burst.
+func newByteLimiter(bytesPerSecond int64) *rate.Limiter {
+	return rate.NewLimiter(rate.Limit(bytesPerSecond), int(bytesPerSecond))
+}
+
+// responseThrottles returns the limiters a response with header must pass
+// through: its own -per-conn-bandwidth limiter, unless Content-Length says
+// it is under -throttle-min-size, and the shared -bandwidth-limit one.
+func responseThrottles(header http.Header) []*rate.Limiter {
+	var limiters []*rate.Limiter
+	if *perConnBandwidthFlag > 0 {
+		length, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+		if err != nil || length >= *throttleMinSizeFlag {
+			limiters = append(limiters, newByteLimiter(*perConnBandwidthFlag))
+		}
+	}
+	if globalBandwidth != nil {
+		limiters = append(limiters, globalBandwidth)
+	}
+	return limiters
+}
+
+// throttledWrite writes p to w in chunks no larger than any limiter's burst,
+// sleeping as each limiter requires. Reservations queue later writers behind
+// earlier ones instead of letting them jump ahead. It blocks rather than
+// failing, so downloads slow down instead of breaking, and the returned
+// count is what w actually accepted.
+func throttledWrite(w io.Writer, p []byte, limiters ...*rate.Limiter) (int, error) {
+	size := throttleChunk
+	for _, l := range limiters {
+		if b := l.Burst(); b < size {
+			size = b
+		}
+	}
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > size {
+			chunk = chunk[:size]
+		}
+		var wait time.Duration
+		now := time.Now()
+		for _, l := range limiters {
+			if d := l.ReserveN(now, len(chunk)).DelayFrom(now); d > wait {
+				wait = d
+			}
+		}
+		time.Sleep(wait)
+		n, err := w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
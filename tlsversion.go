@@ -0,0 +1,50 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+var (
+	minTLSVersionFlag = flag.String("min-tls-version", "", "(optional) -min-tls-version 1.0, 1.1, 1.2 or 1.3. Clients on an older TLS version, from TLS 1.2 up, get 426 Upgrade Required with an explanation instead of a failed handshake")
+	minTLSVersion     uint16
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+func parseMinTLSVersion(value string) (uint16, error) {
+	if value == "" {
+		return 0, nil
+	}
+	version, ok := tlsVersions[value]
+	if !ok {
+		return 0, errors.New("[ERROR] Invalid -min-tls-version, expected 1.0, 1.1, 1.2 or 1.3: " + value)
+	}
+	return version, nil
+}
+
+// minTLSVersionHandler refuses requests made over a TLS version older than
+// -min-tls-version with a readable answer rather than a handshake failure.
+// The server's handshake floor stays at Go's default of TLS 1.2, so only
+// clients between that and -min-tls-version get here.
+func minTLSVersionHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || r.TLS.Version >= minTLSVersion {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		log.Printf("[WARN] Outdated TLS from %s: %s, User-Agent %q", r.RemoteAddr, tls.VersionName(r.TLS.Version), r.UserAgent())
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusUpgradeRequired)
+		fmt.Fprintf(w, "Your browser connected with %s, which is no longer supported. Please update it to one that supports TLS %s or later.\n", tls.VersionName(r.TLS.Version), *minTLSVersionFlag)
+	})
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	upstreamFlag      = flag.String("upstream", "", "(optional) -upstream URL of an origin that requests for missing files are proxied to, e.g. https://origin.example.com")
+	upstreamCacheFlag = flag.Bool("upstream-cache", false, "(optional) -upstream-cache Saves full 200 responses from -upstream into the served directory so later requests are served locally")
+	upstreamURL       *url.URL
+)
+
+func parseUpstream(value string) (*url.URL, error) {
+	if value == "" {
+		if *upstreamCacheFlag {
+			return nil, errors.New("[ERROR] -upstream-cache requires -upstream")
+		}
+		return nil, nil
+	}
+	target, err := url.Parse(value)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, errors.New("[ERROR] Invalid -upstream, expected an http or https URL: " + value)
+	}
+	if *upstreamCacheFlag && *archiveFlag != "" {
+		return nil, errors.New("[ERROR] -upstream-cache cannot write into an -archive")
+	}
+	return target, nil
+}
+
+// upstreamHandler serves what exists in root through handler and proxies
+// everything else to -upstream. With -upstream-cache and a directory root,
+// proxied files are saved under root as they stream to the client.
+func upstreamHandler(handler http.Handler, root http.FileSystem) http.Handler {
+	proxy := httputil.NewSingleHostReverseProxy(upstreamURL)
+	proxy.ErrorLog = serverErrorLog
+	director := proxy.Director
+	proxy.Director = func(r *http.Request) {
+		director(r)
+		// Send the upstream's own name, since virtual hosting there
+		// knows nothing of the names this server answers to.
+		r.Host = upstreamURL.Host
+	}
+	if dir, ok := rootDir(root); ok && *upstreamCacheFlag {
+		proxy.ModifyResponse = func(resp *http.Response) error {
+			cacheUpstreamResponse(string(dir), resp)
+			return nil
+		}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Only a missing file goes upstream. Any other error, such as a
+		// permission denied, is the file server's to answer.
+		f, err := root.Open(path.Clean("/" + r.URL.Path))
+		if !errors.Is(err, fs.ErrNotExist) {
+			if err == nil {
+				f.Close()
+			}
+			handler.ServeHTTP(w, r)
+			return
+		}
+		if notes := notesFrom(r); notes != nil {
+			notes.upstream = true
+		}
+		proxy.ServeHTTP(w, r)
+	})
+}
+
+// cacheUpstreamResponse tees the body of a full, unencoded 200 response to
+// a GET into a temporary file that replaces the file at its path in dir
+// once the whole body has been read. Responses meant for one client only
+// are never saved, since every later client would be served them.
+func cacheUpstreamResponse(dir string, resp *http.Response) {
+	req := resp.Request
+	if req.Method != http.MethodGet || resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Encoding") != "" {
+		return
+	}
+	if !sharedCacheable(resp.Header) {
+		return
+	}
+	name := path.Clean("/" + req.URL.Path)
+	if strings.HasSuffix(req.URL.Path, "/") || name == "/" {
+		return
+	}
+	// The director has already joined the upstream's own path prefix, so
+	// strip it again to get the path the file is served at here.
+	name = path.Clean("/" + strings.TrimPrefix(name, strings.TrimSuffix(upstreamURL.Path, "/")))
+	dest := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		log.Printf("[ERROR] Caching %s: %v", name, err)
+		return
+	}
+	file, err := os.CreateTemp(filepath.Dir(dest), ".upstream-*")
+	if err != nil {
+		log.Printf("[ERROR] Caching %s: %v", name, err)
+		return
+	}
+	resp.Body = &upstreamCache{ReadCloser: resp.Body, file: file, dest: dest, length: resp.ContentLength}
+}
+
+// sharedCacheable reports whether a response may be stored for all
+// clients: not when it sets a cookie or its Cache-Control has no-store or
+// private.
+func sharedCacheable(h http.Header) bool {
+	if len(h.Values("Set-Cookie")) > 0 {
+		return false
+	}
+	for _, value := range h.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name, _, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if strings.EqualFold(name, "no-store") || strings.EqualFold(name, "private") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// upstreamCache copies what is read from an upstream body into file. An
+// incomplete read or a failed write discards the copy.
+type upstreamCache struct {
+	io.ReadCloser
+	file    *os.File
+	dest    string
+	length  int64
+	written int64
+}
+
+func (c *upstreamCache) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	if n > 0 && c.file != nil {
+		if _, werr := c.file.Write(p[:n]); werr != nil {
+			log.Printf("[ERROR] Caching %s: %v", c.dest, werr)
+			c.discard()
+		}
+		c.written += int64(n)
+	}
+	if err == io.EOF && c.file != nil {
+		c.commit()
+	}
+	return n, err
+}
+
+func (c *upstreamCache) Close() error {
+	if c.file != nil {
+		c.discard()
+	}
+	return c.ReadCloser.Close()
+}
+
+func (c *upstreamCache) commit() {
+	if c.length >= 0 && c.written != c.length {
+		c.discard()
+		return
+	}
+	name := c.file.Name()
+	err := c.file.Close()
+	c.file = nil
+	if err == nil {
+		err = os.Chmod(name, 0644)
+	}
+	if err == nil {
+		err = os.Rename(name, c.dest)
+	}
+	if err != nil {
+		log.Printf("[ERROR] Caching %s: %v", c.dest, err)
+		os.Remove(name)
+	}
+}
+
+func (c *upstreamCache) discard() {
+	c.file.Close()
+	os.Remove(c.file.Name())
+	c.file = nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUpstreamCache(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/private.txt":
+			w.Header().Set("Cache-Control", "max-age=60, private")
+		case "/no-store.txt":
+			w.Header().Set("Cache-Control", "no-store")
+		case "/cookie.txt":
+			w.Header().Set("Set-Cookie", "session=abc")
+		}
+		w.Write([]byte("from origin"))
+	}))
+	defer origin.Close()
+	target, err := url.Parse(origin.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := upstreamURL
+	upstreamURL, *upstreamCacheFlag = target, true
+	t.Cleanup(func() { upstreamURL, *upstreamCacheFlag = saved, false })
+	site := newTestSite(t, map[string]string{"local.txt": "local"})
+
+	tests := []struct {
+		name   string
+		cached bool
+	}{
+		{"public.txt", true},
+		{"private.txt", false},
+		{"no-store.txt", false},
+		{"cookie.txt", false},
+	}
+	for _, tt := range tests {
+		w := site.get("GET", "/"+tt.name, nil)
+		if w.Code != http.StatusOK || w.Body.String() != "from origin" {
+			t.Errorf("%s: got %d %q, want the origin's 200", tt.name, w.Code, w.Body)
+		}
+		_, err := os.Stat(filepath.Join(site.dir, tt.name))
+		if cached := err == nil; cached != tt.cached {
+			t.Errorf("%s: cached %v, want %v", tt.name, cached, tt.cached)
+		}
+	}
+	if w := site.get("GET", "/local.txt", nil); w.Body.String() != "local" {
+		t.Errorf("local file: got %q, want it served from disk", w.Body)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+var (
+	varyFlag    = flag.String("vary", "", "(optional) -vary Comma separated request header names added to the Vary header of every response, for variants chosen outside this server such as by Cookie")
+	varyHeaders []string
+)
+
+func parseVaryHeaders(value string) []string {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			names = append(names, textproto.CanonicalMIMEHeaderKey(name))
+		}
+	}
+	return names
+}
+
+// addVary adds names to the Vary header of h, leaving out those already
+// listed so middlewares can each declare what they negotiate on.
+func addVary(h http.Header, names ...string) {
+	listed := map[string]bool{}
+	for _, value := range h.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			listed[strings.ToLower(strings.TrimSpace(name))] = true
+		}
+	}
+	if listed["*"] {
+		return
+	}
+	var missing []string
+	for _, name := range names {
+		if !listed[strings.ToLower(name)] {
+			listed[strings.ToLower(name)] = true
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+	if existing := h.Get("Vary"); existing != "" && len(h.Values("Vary")) == 1 {
+		h.Set("Vary", existing+", "+strings.Join(missing, ", "))
+		return
+	}
+	h.Add("Vary", strings.Join(missing, ", "))
+}
+
+// varyHandler adds the -vary headers to every response.
+func varyHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addVary(w.Header(), varyHeaders...)
+		handler.ServeHTTP(w, r)
+	})
+}
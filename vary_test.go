@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestAddVary(t *testing.T) {
+	tests := []struct {
+		existing []string
+		names    []string
+		want     []string
+	}{
+		{nil, []string{"Accept-Encoding"}, []string{"Accept-Encoding"}},
+		{nil, []string{"Accept-Encoding", "Accept-Encoding"}, []string{"Accept-Encoding"}},
+		{[]string{"Cookie"}, []string{"Accept-Encoding", "Save-Data"}, []string{"Cookie, Accept-Encoding, Save-Data"}},
+		{[]string{"Accept-Encoding"}, []string{"accept-encoding"}, []string{"Accept-Encoding"}},
+		{[]string{"Cookie, Accept-Encoding"}, []string{"Accept-Encoding", "Accept-Language"}, []string{"Cookie, Accept-Encoding, Accept-Language"}},
+		{[]string{"Cookie", "Origin"}, []string{"Origin", "Accept-Encoding"}, []string{"Cookie", "Origin", "Accept-Encoding"}},
+		{[]string{"*"}, []string{"Accept-Encoding"}, []string{"*"}},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		for _, value := range tt.existing {
+			h.Add("Vary", value)
+		}
+		addVary(h, tt.names...)
+		if got := h.Values("Vary"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Vary %q plus %q: got %q, want %q", tt.existing, tt.names, got, tt.want)
+		}
+	}
+}
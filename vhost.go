@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"net/http"
+	"os"
+	"strings"
+)
+
+var (
+	vhostFlags stringList
+	// vhosts maps a normalized host name to the directory served for it.
+	vhosts map[string]string
+)
+
+func init() {
+	flag.Var(&vhostFlags, "vhost", "(optional) -vhost 'host=dir' Serves dir to requests for host instead of -d, which still serves every other host. Repeatable")
+}
+
+func parseVhosts(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	mappings := map[string]string{}
+	for _, value := range values {
+		host, dir, ok := strings.Cut(value, "=")
+		if !ok || host == "" || dir == "" {
+			return nil, errors.New("[ERROR] Invalid vhost, expected host=dir: " + value)
+		}
+		host = normalizeHost(host)
+		if _, exists := mappings[host]; exists {
+			return nil, errors.New("[ERROR] Duplicate vhost " + host)
+		}
+		info, err := os.Stat(dir)
+		if err != nil || !info.IsDir() {
+			return nil, errors.New("[ERROR] Vhost directory invalid: " + dir)
+		}
+		mappings[host] = dir
+	}
+	return mappings, nil
+}
+
+// vhostHandler picks the site for a request by its Host header, handing
+// hosts without a -vhost mapping to fallback.
+func vhostHandler(fallback http.Handler) http.Handler {
+	sites := make(map[string]http.Handler, len(vhosts))
+	for host, dir := range vhosts {
+		sites[host] = siteHandler(http.Dir(dir))
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		site, ok := sites[normalizeHost(r.Host)]
+		if !ok {
+			site = fallback
+		}
+		site.ServeHTTP(w, r)
+	})
+}
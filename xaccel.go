@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+var (
+	xaccelFlag = flag.String("xaccel", "", "(optional) -xaccel Internal nginx location, e.g. /protected/, that files are handed off to with X-Accel-Redirect instead of being sent")
+)
+
+// xaccelHandler answers requests for regular files in root with an empty
+// response carrying X-Accel-Redirect, so nginx in front delivers the file
+// from its internal -xaccel location. Everything else, including listings
+// and missing files, goes to handler.
+func xaccelHandler(handler http.Handler, root http.FileSystem) http.Handler {
+	prefix := strings.TrimSuffix(*xaccelFlag, "/")
+	base := servedPathBase(root)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean("/" + r.URL.Path)
+		if strings.HasSuffix(r.URL.Path, "/") || !fileExists(root, name) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		noteServedPath(r, base, name)
+		// nginx decodes the header as a URI, so names with spaces, %, ? or
+		// non-ASCII characters have to be escaped to reach the file.
+		w.Header().Set("X-Accel-Redirect", (&url.URL{Path: prefix + name}).EscapedPath())
+		w.WriteHeader(http.StatusOK)
+	})
+}